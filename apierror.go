@@ -0,0 +1,60 @@
+package douyulive
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// 斗鱼开放平台接口的错误码
+const (
+	APICodeOK            = 0   // 成功
+	APICodeInvalidParams = 400 // 参数错误
+	APICodeBadSignature  = 401 // auth签名校验失败，一般是aid或secret错误
+	APICodeExpiredTime   = 402 // 请求的time与服务器时间相差过大
+	APICodeForbidden     = 403 // 没有接口权限
+	APICodeRateLimited   = 429 // 请求过于频繁
+	APICodeServerError   = 500 // 服务器内部错误
+)
+
+// 可配合errors.Is判断错误码
+var (
+	ErrBadSignature = &APIError{Code: APICodeBadSignature, Msg: "签名错误"}
+	ErrExpiredTime  = &APIError{Code: APICodeExpiredTime, Msg: "请求时间过期"}
+	ErrForbidden    = &APIError{Code: APICodeForbidden, Msg: "没有接口权限"}
+	ErrRateLimited  = &APIError{Code: APICodeRateLimited, Msg: "请求过于频繁"}
+)
+
+// APIError 斗鱼开放平台接口返回的错误，可通过errors.As获取
+type APIError struct {
+	Endpoint   string // 接口路径，如/api/thirdPart/token
+	StatusCode int    // HTTP状态码，接口返回了错误码时为200
+	Code       int64  // 接口返回的错误码，HTTP请求失败且响应不是接口格式时为0
+	Msg        string // 接口返回的错误信息
+}
+
+func (e *APIError) Error() string {
+	if e.Code == APICodeOK && e.StatusCode != 0 && e.StatusCode != http.StatusOK {
+		return fmt.Sprintf("斗鱼接口 %s 请求失败: HTTP %d", e.Endpoint, e.StatusCode)
+	}
+	return fmt.Sprintf("斗鱼接口 %s 返回错误 %d: %s", e.Endpoint, e.Code, e.Msg)
+}
+
+// Is 错误码相同即视为相同的错误
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	if !ok {
+		return false
+	}
+	return t.Code != APICodeOK && t.Code == e.Code
+}
+
+// Temporary 是否为稍后重试可能成功的错误
+func (e *APIError) Temporary() bool {
+	switch {
+	case e.Code == APICodeRateLimited, e.Code == APICodeServerError:
+		return true
+	case e.StatusCode == http.StatusTooManyRequests, e.StatusCode >= http.StatusInternalServerError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package douyulive
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestTokenInfo_APIError(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		code      int64
+		target    error
+		temporary bool
+	}{
+		{"bad signature", http.StatusOK, `{"code":401,"msg":"auth error","data":null}`, APICodeBadSignature, ErrBadSignature, false},
+		{"expired time", http.StatusOK, `{"code":402,"msg":"time expired","data":null}`, APICodeExpiredTime, ErrExpiredTime, false},
+		{"rate limited", http.StatusOK, `{"code":429,"msg":"too many requests","data":null}`, APICodeRateLimited, ErrRateLimited, true},
+		{"http error", http.StatusBadGateway, `bad gateway`, 0, nil, true},
+		{"http error with code", http.StatusTooManyRequests, `{"code":429,"msg":"too many requests"}`, APICodeRateLimited, ErrRateLimited, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			info, err := requestTokenInfo(context.Background(), nil, server.URL, "aid", "secret", time.Now())
+			if info != nil {
+				t.Fatalf("info = %+v", info)
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %v, want APIError", err)
+			}
+			if apiErr.Endpoint != tokenEndpoint || apiErr.StatusCode != tt.status || apiErr.Code != tt.code {
+				t.Errorf("api error = %+v", apiErr)
+			}
+			if tt.target != nil && !errors.Is(err, tt.target) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.target)
+			}
+			if errors.Is(err, ErrForbidden) {
+				t.Errorf("errors.Is(%v, ErrForbidden) = true", err)
+			}
+			if apiErr.Temporary() != tt.temporary {
+				t.Errorf("Temporary() = %v", apiErr.Temporary())
+			}
+		})
+	}
+}
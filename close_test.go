@@ -0,0 +1,240 @@
+package douyulive
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 弹幕服务器一端，记录收到的消息类型并响应登录请求
+type pipeServer struct {
+	conn  net.Conn
+	mu    sync.Mutex
+	types []string
+	done  chan struct{}
+}
+
+func newPipeServer(conn net.Conn) *pipeServer {
+	s := &pipeServer{conn: conn, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		reader := NewFrameReader(conn)
+		for {
+			_, body, err := reader.ReadFrame()
+			if err != nil {
+				return
+			}
+			msgType := ByteToMsg(body)["type"]
+			s.mu.Lock()
+			s.types = append(s.types, msgType)
+			s.mu.Unlock()
+			if msgType == "loginreq" {
+				// 客户端在登录后才开始读取，不能阻塞读取
+				go func() { _ = s.send(map[string]string{"type": LoginRespType}) }()
+			}
+		}
+	}()
+	return s
+}
+
+func (s *pipeServer) send(msg map[string]string) error {
+	data := serializeMsg(msg)
+	_, err := s.conn.Write(EncodeFrame(ServerMsgType, []byte(data)))
+	return err
+}
+
+func (s *pipeServer) received(msgType string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.types {
+		if t == msgType {
+			return true
+		}
+	}
+	return false
+}
+
+// 添加一个使用net.Pipe连接的房间
+func joinPipeRoom(live *Live, roomID int) *pipeServer {
+	client, server := net.Pipe()
+	ctx, cancel := context.WithCancel(live.ctx)
+	room := &liveRoom{roomID: roomID, cancel: cancel, conn: client, metrics: live.metrics}
+	_ = live.rooms.add(room, func() { live.runRoom(ctx, room) })
+	return newPipeServer(server)
+}
+
+// 等待协程数量回落到before，超时则输出所有协程的堆栈
+func checkGoroutineLeak(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			n := runtime.Stack(buf, true)
+			t.Fatalf("协程泄漏: %d > %d\n%s", runtime.NumGoroutine(), before, buf[:n])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLive_Close(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	received := make(chan string, 1)
+	live := &Live{
+		AnalysisRoutineNum: 2,
+		BarrageMessageHandler: func(roomID int, msg *BarrageMessageModel) {
+			received <- msg.Txt
+		},
+	}
+	live.Start(context.Background())
+
+	servers := []*pipeServer{joinPipeRoom(live, 1), joinPipeRoom(live, 2)}
+	if err := servers[0].send(map[string]string{"type": BarrageRespType, "txt": "hello"}); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	select {
+	case txt := <-received:
+		if txt != "hello" {
+			t.Fatalf("handler got %q, want hello", txt)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("没有收到弹幕消息")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := live.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	live.Wait()
+
+	for i, s := range servers {
+		<-s.done
+		if !s.received("logout") {
+			t.Errorf("房间 %d 没有收到logout", i+1)
+		}
+	}
+	if err := live.Close(ctx); err != ErrClosed {
+		t.Errorf("Close() again error = %v, want ErrClosed", err)
+	}
+	if err := live.Join("", "", nil, 3); err != ErrClosed {
+		t.Errorf("Join() after Close error = %v, want ErrClosed", err)
+	}
+	checkGoroutineLeak(t, before)
+}
+
+func TestLive_ClosePolicy(t *testing.T) {
+	tests := []struct {
+		policy ClosePolicy
+		want   int32
+	}{
+		{CloseDrain, 6},
+		{CloseDrop, 1},
+	}
+	for _, tt := range tests {
+		before := runtime.NumGoroutine()
+
+		var handled int32
+		started := make(chan struct{})
+		release := make(chan struct{})
+		live := &Live{
+			ClosePolicy: tt.policy,
+			BarrageMessageHandler: func(roomID int, msg *BarrageMessageModel) {
+				if atomic.AddInt32(&handled, 1) == 1 {
+					close(started)
+					<-release
+				}
+			},
+		}
+		live.Start(context.Background())
+		for i := 0; i < 6; i++ {
+			live.workers[0].chSocketMessage <- &socketMessage{roomID: 1, body: map[string]string{"type": BarrageRespType}}
+		}
+		<-started
+
+		// 第一条消息处理期间关闭，其余5条留在队列中
+		closed := make(chan error)
+		go func() {
+			closed <- live.Close(context.Background())
+		}()
+		for !live.isClosed() {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+
+		if err := <-closed; err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		if got := atomic.LoadInt32(&handled); got != tt.want {
+			t.Errorf("policy %d handled = %d, want %d", tt.policy, got, tt.want)
+		}
+		checkGoroutineLeak(t, before)
+	}
+}
+
+func TestLive_CloseTimeout(t *testing.T) {
+	block := make(chan struct{})
+	live := &Live{
+		BarrageMessageHandler: func(roomID int, msg *BarrageMessageModel) {
+			<-block
+		},
+	}
+	live.Start(context.Background())
+	live.workers[0].chSocketMessage <- &socketMessage{roomID: 1, body: map[string]string{"type": BarrageRespType}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := live.Close(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Close() error = %v, want DeadlineExceeded", err)
+	}
+	close(block)
+	live.Wait()
+}
+
+func TestLive_StalledJoinGroupWrite(t *testing.T) {
+	stalled := make(chan struct{})
+	live := &Live{
+		TokenProvider: testToken,
+		Dialer: &Dialer{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				client, server := net.Pipe()
+				// 响应登录后不再读取，入组请求的写入会一直阻塞
+				go func() {
+					_, _, _ = NewFrameReader(server).ReadFrame()
+					_, _ = server.Write(loginRespFrame())
+					close(stalled)
+				}()
+				return client, nil
+			},
+		},
+	}
+	live.Start(context.Background())
+	if err := live.Join("aid", "secret", []*HostServer{{Host: "danmu.test", Port: 8601}}, 1); err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+	<-stalled
+
+	statusDone := make(chan struct{})
+	go func() {
+		_, _ = live.Status(1)
+		_ = live.AllStatus()
+		close(statusDone)
+	}()
+	select {
+	case <-statusDone:
+	case <-time.After(time.Second):
+		t.Fatal("入组写入阻塞时Status没有返回")
+	}
+
+	// 登出请求最多等待1秒写超时
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := live.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
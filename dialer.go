@@ -0,0 +1,239 @@
+package douyulive
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultDialTimeout 默认连接超时
+const DefaultDialTimeout = 10 * time.Second
+
+// 代理错误
+var ErrProxy = errors.New("代理连接失败")
+
+// Dialer 建立到弹幕服务器的底层连接，零值可直接使用
+type Dialer struct {
+	Timeout   time.Duration // 连接超时，包括代理握手，默认DefaultDialTimeout
+	KeepAlive time.Duration // TCP keep-alive间隔，0使用系统默认值，小于0关闭
+	Network   string        // tcp（默认，IPv4与IPv6双栈）、tcp4或tcp6
+	Proxy     *url.URL      // 代理地址，支持 socks5://[user:pass@]host:port 与 http://[user:pass@]host:port
+
+	// DialContext 自定义拨号函数，设置后用它连接代理或弹幕服务器，Network与KeepAlive不再生效
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// 拨号超时，未设置时使用DefaultDialTimeout
+func (d *Dialer) timeout() time.Duration {
+	if d == nil || d.Timeout <= 0 {
+		return DefaultDialTimeout
+	}
+	return d.Timeout
+}
+
+// Dial 连接addr，设置了代理时通过代理连接
+func (d *Dialer) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	if d == nil {
+		d = &Dialer{}
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.timeout())
+	defer cancel()
+
+	if d.Proxy == nil {
+		return d.dial(ctx, addr)
+	}
+
+	conn, err := d.dial(ctx, proxyAddress(d.Proxy))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProxy, err)
+	}
+	// 代理握手期间遵循超时
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	switch d.Proxy.Scheme {
+	case "socks5", "socks5h":
+		err = socks5Connect(conn, d.Proxy.User, addr)
+	case "http":
+		var tunnel net.Conn
+		if tunnel, err = httpConnect(conn, d.Proxy.User, addr); err == nil {
+			conn = tunnel
+		}
+	default:
+		err = fmt.Errorf("%w: 不支持的代理类型 %s", ErrProxy, d.Proxy.Scheme)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (d *Dialer) dial(ctx context.Context, addr string) (net.Conn, error) {
+	network := d.Network
+	if network == "" {
+		network = "tcp"
+	}
+	if d.DialContext != nil {
+		return d.DialContext(ctx, network, addr)
+	}
+	nd := &net.Dialer{KeepAlive: d.KeepAlive}
+	return nd.DialContext(ctx, network, addr)
+}
+
+// 代理地址，未指定端口时使用默认端口
+func proxyAddress(proxy *url.URL) string {
+	if proxy.Port() != "" {
+		return proxy.Host
+	}
+	port := "1080"
+	if proxy.Scheme == "http" {
+		port = "80"
+	}
+	return net.JoinHostPort(proxy.Hostname(), port)
+}
+
+// SOCKS5 CONNECT，RFC 1928、RFC 1929
+func socks5Connect(conn net.Conn, user *url.Userinfo, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return err
+	}
+
+	// 协商认证方式
+	methods := []byte{0x00}
+	if user != nil {
+		methods = []byte{0x02}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 || reply[1] != methods[0] {
+		return fmt.Errorf("%w: socks5不接受认证方式 %d", ErrProxy, reply[1])
+	}
+	if user != nil {
+		password, _ := user.Password()
+		username := user.Username()
+		if len(username) > 255 || len(password) > 255 {
+			return fmt.Errorf("%w: socks5用户名或密码过长", ErrProxy)
+		}
+		auth := []byte{0x01, byte(len(username))}
+		auth = append(auth, username...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0x00 {
+			return fmt.Errorf("%w: socks5认证失败", ErrProxy)
+		}
+	}
+
+	// 请求连接目标地址
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, 0x01)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, 0x04)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("%w: 域名过长", ErrProxy)
+		}
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	if head[1] != 0x00 {
+		return fmt.Errorf("%w: socks5响应码 %d", ErrProxy, head[1])
+	}
+	// 跳过绑定地址与端口
+	var skip int
+	switch head[3] {
+	case 0x01:
+		skip = net.IPv4len + 2
+	case 0x04:
+		skip = net.IPv6len + 2
+	case 0x03:
+		if _, err := io.ReadFull(conn, head[:1]); err != nil {
+			return err
+		}
+		skip = int(head[0]) + 2
+	default:
+		return fmt.Errorf("%w: socks5地址类型 %d", ErrProxy, head[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip))
+	return err
+}
+
+// HTTP CONNECT隧道
+func httpConnect(conn net.Conn, user *url.Userinfo, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user != nil {
+		password, _ := user.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: http代理响应 %s", ErrProxy, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// 先读出握手时已缓冲的数据
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,252 @@
+package douyulive
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 本地回显服务器
+func listenEcho(t *testing.T, network, address string) net.Addr {
+	t.Helper()
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		t.Skipf("Listen(%s, %s) error = %v", network, address, err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(conn, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+	return ln.Addr()
+}
+
+// 本地代理，handshake完成代理握手并返回目标地址
+func listenProxy(t *testing.T, handshake func(conn net.Conn, br *bufio.Reader) (string, error)) *url.URL {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				target, err := handshake(conn, br)
+				if err != nil {
+					return
+				}
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				go func() { _, _ = io.Copy(upstream, br) }()
+				_, _ = io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return &url.URL{Host: ln.Addr().String()}
+}
+
+// 支持用户名密码认证的SOCKS5代理
+func socks5Handshake(conn net.Conn, br *bufio.Reader) (string, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return "", err
+	}
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(br, methods); err != nil {
+		return "", err
+	}
+	if _, err := conn.Write([]byte{0x05, 0x02}); err != nil {
+		return "", err
+	}
+	// 用户名密码
+	if _, err := io.ReadFull(br, head); err != nil {
+		return "", err
+	}
+	user := make([]byte, head[1])
+	_, _ = io.ReadFull(br, user)
+	plen, _ := br.ReadByte()
+	pass := make([]byte, plen)
+	_, _ = io.ReadFull(br, pass)
+	if string(user) != "user" || string(pass) != "pass" {
+		_, _ = conn.Write([]byte{0x01, 0x01})
+		return "", errors.New("认证失败")
+	}
+	_, _ = conn.Write([]byte{0x01, 0x00})
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(br, req); err != nil {
+		return "", err
+	}
+	var host string
+	switch req[3] {
+	case 0x01:
+		ip := make([]byte, 4)
+		_, _ = io.ReadFull(br, ip)
+		host = net.IP(ip).String()
+	case 0x03:
+		n, _ := br.ReadByte()
+		name := make([]byte, n)
+		_, _ = io.ReadFull(br, name)
+		host = string(name)
+	}
+	port := make([]byte, 2)
+	_, _ = io.ReadFull(br, port)
+	_, _ = conn.Write([]byte{0x05, 0x00, 0x00, 0x03, 0x09, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't', 0x00, 0x00})
+	return net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1]))), nil
+}
+
+// 需要Basic认证的HTTP CONNECT代理，响应后紧跟一段数据以检查缓冲处理
+func httpConnectHandshake(conn net.Conn, br *bufio.Reader) (string, error) {
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return "", err
+	}
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	if req.Method != http.MethodConnect || req.Header.Get("Proxy-Authorization") != want {
+		_, _ = conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+		return "", errors.New("认证失败")
+	}
+	_, err = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\nhello"))
+	return req.Host, err
+}
+
+func TestDialer_Proxy(t *testing.T) {
+	target := listenEcho(t, "tcp", "127.0.0.1:0").String()
+
+	tests := []struct {
+		name      string
+		scheme    string
+		handshake func(conn net.Conn, br *bufio.Reader) (string, error)
+		greeting  string
+	}{
+		{"socks5", "socks5", socks5Handshake, ""},
+		{"http", "http", httpConnectHandshake, "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := listenProxy(t, tt.handshake)
+			proxy.Scheme = tt.scheme
+			proxy.User = url.UserPassword("user", "pass")
+
+			conn, err := (&Dialer{Proxy: proxy, Timeout: 3 * time.Second}).Dial(context.Background(), target)
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer conn.Close()
+
+			want := tt.greeting + "ping"
+			if _, err := conn.Write([]byte("ping")); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			got := make([]byte, len(want))
+			if _, err := io.ReadFull(conn, got); err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if string(got) != want {
+				t.Fatalf("Read() = %q, want %q", got, want)
+			}
+
+			proxy.User = url.UserPassword("user", "wrong")
+			if _, err := (&Dialer{Proxy: proxy}).Dial(context.Background(), target); !errors.Is(err, ErrProxy) {
+				t.Fatalf("Dial() 认证失败 error = %v, want ErrProxy", err)
+			}
+		})
+	}
+}
+
+func TestDialer_Timeout(t *testing.T) {
+	d := &Dialer{
+		Timeout: 50 * time.Millisecond,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	start := time.Now()
+	if _, err := d.Dial(context.Background(), "127.0.0.1:1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Dial() error = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Dial() 超时用了 %v", elapsed)
+	}
+}
+
+func TestDialer_IPv6(t *testing.T) {
+	addr := listenEcho(t, "tcp6", "[::1]:0")
+	conn, err := (&Dialer{Network: "tcp6", KeepAlive: time.Second}).Dial(context.Background(), addr.String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	_ = conn.Close()
+
+	if _, err := (&Dialer{Network: "tcp4"}).Dial(context.Background(), addr.String()); err == nil {
+		t.Fatal("Dial() tcp4 连接IPv6地址 error = nil")
+	}
+}
+
+func TestLive_Dialer(t *testing.T) {
+
+	dialed := make(chan string, 1)
+	servers := make(chan *pipeServer, 1)
+	received := make(chan string, 1)
+	live := &Live{
+		TokenProvider: testToken,
+		Dialer: &Dialer{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				dialed <- addr
+				client, server := net.Pipe()
+				servers <- newPipeServer(server)
+				return client, nil
+			},
+		},
+		BarrageMessageHandler: func(roomID int, msg *BarrageMessageModel) {
+			received <- msg.Txt
+		},
+	}
+	live.Start(context.Background())
+	defer live.Close(context.Background())
+
+	if err := live.Join("aid", "secret", []*HostServer{{Host: "danmu.test", Port: 8601}}, 1); err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+	if addr := <-dialed; addr != "danmu.test:8601" {
+		t.Fatalf("DialContext() addr = %s, want danmu.test:8601", addr)
+	}
+	if err := (<-servers).send(map[string]string{"type": BarrageRespType, "txt": "pipe"}); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	select {
+	case txt := <-received:
+		if txt != "pipe" {
+			t.Fatalf("handler got %q, want pipe", txt)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("没有收到弹幕消息")
+	}
+}
@@ -0,0 +1,64 @@
+package fakeserver
+
+import (
+	"strconv"
+	"time"
+)
+
+// LoginResponse 登录成功的响应
+func LoginResponse(roomID int) map[string]string {
+	return map[string]string{
+		"type":      "loginres",
+		"userid":    "0",
+		"roomgroup": "0",
+		"pg":        "0",
+		"sessionid": strconv.Itoa(roomID),
+		"username":  "",
+		"nickname":  "",
+		"live_stat": "0",
+		"now":       strconv.FormatInt(time.Now().Unix(), 10),
+	}
+}
+
+// ErrorMessage 服务器错误消息
+func ErrorMessage(code int64) map[string]string {
+	return map[string]string{
+		"type": "error",
+		"code": strconv.FormatInt(code, 10),
+	}
+}
+
+// ChatMessage 弹幕消息
+func ChatMessage(uid int64, nickName, txt string) map[string]string {
+	return map[string]string{
+		"type":  "chatmsg",
+		"uid":   strconv.FormatInt(uid, 10),
+		"nn":    nickName,
+		"txt":   txt,
+		"level": "1",
+	}
+}
+
+// GiftMessage 赠送礼物消息
+func GiftMessage(uid int64, nickName string, giftID, count int64) map[string]string {
+	return map[string]string{
+		"type":  "dgb",
+		"uid":   strconv.FormatInt(uid, 10),
+		"nn":    nickName,
+		"gfid":  strconv.FormatInt(giftID, 10),
+		"gfcnt": strconv.FormatInt(count, 10),
+		"hits":  "1",
+	}
+}
+
+// SwitchBroadcastMessage 房间开关播提醒，live为true表示开播
+func SwitchBroadcastMessage(live bool) map[string]string {
+	status := "0"
+	if live {
+		status = "1"
+	}
+	return map[string]string{
+		"type": "rss",
+		"ss":   status,
+	}
+}
@@ -0,0 +1,300 @@
+// Package fakeserver 提供进程内的斗鱼弹幕服务器与token接口，用于离线测试
+package fakeserver
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	douyulive "douyu-barrage"
+	"douyu-barrage/stt"
+)
+
+// Faults 注入的故障
+type Faults struct {
+	ReadDelay       time.Duration // 每读取一帧前等待的时间，模拟服务端读取缓慢
+	DropAfterFrames int           // 每个连接发送多少帧后断开，0表示不断开
+	MalformedLogin  bool          // 用长度字段不一致的畸形帧响应loginreq
+	LoginErrorCode  int64         // 不为0时用该错误码的error消息响应loginreq
+	IgnoreLogin     bool          // 不响应loginreq
+	NoHeartbeatEcho bool          // 不回复客户端的心跳
+}
+
+// Server 进程内的斗鱼弹幕服务器，使用689/690消息帧，
+// 处理loginreq、joingroup、mrkl与logout，客户端入组后按顺序推送Script中的消息
+type Server struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	script   []map[string]string
+	faults   Faults
+	conns    map[*serverConn]struct{}
+	received []map[string]string
+	changed  chan struct{} // 收到新消息时关闭并重建
+}
+
+// NewServer 在本地随机端口启动弹幕服务器
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		ln:      ln,
+		conns:   make(map[*serverConn]struct{}),
+		changed: make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr 监听地址
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// HostServer 返回可传给Live.Join的服务器地址
+func (s *Server) HostServer() *douyulive.HostServer {
+	addr := s.ln.Addr().(*net.TCPAddr)
+	return &douyulive.HostServer{Host: addr.IP.String(), Port: addr.Port}
+}
+
+// SetScript 设置客户端入组后推送的消息，消息中的rid会替换为客户端的房间号
+func (s *Server) SetScript(msgs ...map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = msgs
+}
+
+// SetFaults 设置注入的故障，对之后的读写生效
+func (s *Server) SetFaults(faults Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = faults
+}
+
+func (s *Server) currentFaults() Faults {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.faults
+}
+
+// Push 向已入组的房间连接推送消息，返回推送的连接数
+func (s *Server) Push(roomID int, msg map[string]string) int {
+	s.mu.Lock()
+	conns := make([]*serverConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	var n int
+	for _, c := range conns {
+		if c.joinedRoom() != roomID {
+			continue
+		}
+		if err := c.send(withRoomID(msg, roomID)); err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// DropConnections 断开所有客户端连接
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		_ = c.conn.Close()
+	}
+}
+
+// Connections 当前的客户端连接数
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// Received 返回收到的指定类型的消息，msgType为空时返回全部
+func (s *Server) Received(msgType string) []map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var msgs []map[string]string
+	for _, msg := range s.received {
+		if msgType == "" || msg["type"] == msgType {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// WaitFor 等待收到至少n条指定类型的消息
+func (s *Server) WaitFor(ctx context.Context, msgType string, n int) error {
+	for {
+		s.mu.Lock()
+		changed := s.changed
+		s.mu.Unlock()
+		if len(s.Received(msgType)) >= n {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Close 停止监听并断开所有连接
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.DropConnections()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &serverConn{server: s, conn: conn}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			c.serve()
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+func (s *Server) record(msg map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received = append(s.received, msg)
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// 一个客户端连接
+type serverConn struct {
+	server *Server
+	conn   net.Conn
+
+	mu     sync.Mutex
+	roomID int // 入组的房间号，0表示未入组
+	sent   int // 已发送的帧数
+}
+
+func (c *serverConn) joinedRoom() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.roomID
+}
+
+func (c *serverConn) serve() {
+	defer c.conn.Close()
+
+	reader := douyulive.NewFrameReader(c.conn)
+	var loginRoomID int
+	for {
+		if delay := c.server.currentFaults().ReadDelay; delay > 0 {
+			time.Sleep(delay)
+		}
+		_, body, err := reader.ReadFrame()
+		if err != nil {
+			return
+		}
+		msg := make(map[string]string)
+		if err := stt.Unmarshal(body, &msg); err != nil {
+			return
+		}
+		c.server.record(msg)
+
+		switch msg["type"] {
+		case "loginreq":
+			loginRoomID, _ = strconv.Atoi(msg["roomid"])
+			faults := c.server.currentFaults()
+			switch {
+			case faults.IgnoreLogin:
+			case faults.MalformedLogin:
+				c.sendMalformed()
+			case faults.LoginErrorCode != 0:
+				_ = c.send(ErrorMessage(faults.LoginErrorCode))
+			default:
+				_ = c.send(LoginResponse(loginRoomID))
+			}
+		case "joingroup":
+			c.mu.Lock()
+			c.roomID = loginRoomID
+			c.mu.Unlock()
+
+			c.server.mu.Lock()
+			script := c.server.script
+			c.server.mu.Unlock()
+			for _, msg := range script {
+				if err := c.send(withRoomID(msg, loginRoomID)); err != nil {
+					return
+				}
+			}
+		case "mrkl":
+			if !c.server.currentFaults().NoHeartbeatEcho {
+				_ = c.send(map[string]string{"type": "mrkl"})
+			}
+		case "logout":
+			return
+		}
+	}
+}
+
+func (c *serverConn) send(msg map[string]string) error {
+	body, err := stt.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.write(douyulive.EncodeFrame(douyulive.ServerMsgType, append(body, 0)))
+}
+
+// 发送两个长度字段不一致的帧
+func (c *serverConn) sendMalformed() {
+	frame := douyulive.EncodeFrame(douyulive.ServerMsgType, []byte("type@=loginres/\x00"))
+	binary.LittleEndian.PutUint32(frame[4:8], 0)
+	_ = c.write(frame)
+}
+
+func (c *serverConn) write(frame []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.conn.Write(frame); err != nil {
+		return err
+	}
+	c.sent++
+	if drop := c.server.currentFaults().DropAfterFrames; drop > 0 && c.sent >= drop {
+		_ = c.conn.Close()
+	}
+	return nil
+}
+
+// 复制消息并设置rid
+func withRoomID(msg map[string]string, roomID int) map[string]string {
+	m := make(map[string]string, len(msg)+1)
+	for k, v := range msg {
+		m[k] = v
+	}
+	m["rid"] = strconv.Itoa(roomID)
+	return m
+}
@@ -0,0 +1,85 @@
+package fakeserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	douyulive "douyu-barrage"
+)
+
+// TokenPath token接口路径
+const TokenPath = "/api/thirdPart/token"
+
+// 请求的time与当前时间允许的最大偏差
+const maxTimeSkew = 5 * time.Minute
+
+// TokenHandler 模拟斗鱼开放平台的token接口
+type TokenHandler struct {
+	Aid    string // 为空时不校验aid与签名
+	Secret string
+	Token  string // 返回的token
+	Expire int    // 返回的有效期，单位秒
+	Code   int64  // 不为0时总是返回该错误码，用于模拟限流等错误
+
+	calls int32
+}
+
+// Calls 接口被调用的次数
+func (h *TokenHandler) Calls() int {
+	return int(atomic.LoadInt32(&h.calls))
+}
+
+func (h *TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&h.calls, 1)
+	if r.URL.Path != TokenPath {
+		http.NotFound(w, r)
+		return
+	}
+
+	if code := h.Code; code != douyulive.APICodeOK {
+		writeError(w, code, "error")
+		return
+	}
+
+	query := r.URL.Query()
+	requestTime, err := strconv.ParseInt(query.Get("time"), 10, 64)
+	if err != nil {
+		writeError(w, douyulive.APICodeInvalidParams, "invalid time")
+		return
+	}
+	if d := time.Since(time.Unix(requestTime, 0)); d > maxTimeSkew || d < -maxTimeSkew {
+		writeError(w, douyulive.APICodeExpiredTime, "time expired")
+		return
+	}
+	if h.Aid != "" {
+		auth := douyulive.Md5(fmt.Sprintf("%s?aid=%s&time=%s%s", TokenPath, query.Get("aid"), query.Get("time"), h.Secret))
+		if query.Get("aid") != h.Aid || query.Get("auth") != auth {
+			writeError(w, douyulive.APICodeBadSignature, "auth error")
+			return
+		}
+	}
+	writeJSON(w, map[string]interface{}{
+		"code": 0,
+		"msg":  "ok",
+		"data": map[string]interface{}{"token": h.Token, "expire": h.Expire},
+	})
+}
+
+func writeError(w http.ResponseWriter, code int64, msg string) {
+	writeJSON(w, map[string]interface{}{"code": code, "msg": msg, "data": nil})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// NewTokenServer 启动token接口，返回的服务器URL可作为Live.OpenAPIBaseURL
+func NewTokenServer(h *TokenHandler) *httptest.Server {
+	return httptest.NewServer(h)
+}
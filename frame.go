@@ -0,0 +1,98 @@
+package douyulive
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 消息类型
+const (
+	ClientMsgType uint16 = 689 // 客户端发送给弹幕服务器的消息
+	ServerMsgType uint16 = 690 // 弹幕服务器发送给客户端的消息
+)
+
+// DefaultMaxFrameLen 默认单帧最大长度（不含首个长度字段）
+const DefaultMaxFrameLen uint32 = 1 << 20
+
+// 帧头长度：两个长度字段 + 消息类型 + 加密字段与保留字段
+const frameHeaderLen = HeadLen*2 + MsgTypeLen + KeepLen
+
+// 帧解析错误
+var (
+	ErrShortFrame     = errors.New("消息帧不完整")
+	ErrLengthMismatch = errors.New("消息帧两个长度字段不一致")
+	ErrFrameTooLarge  = errors.New("消息帧超过最大长度")
+	ErrMsgType        = errors.New("未知的消息类型")
+)
+
+// 是否为帧解析错误，而非连接本身的错误
+func isFrameError(err error) bool {
+	return errors.Is(err, ErrShortFrame) || errors.Is(err, ErrLengthMismatch) ||
+		errors.Is(err, ErrFrameTooLarge) || errors.Is(err, ErrMsgType)
+}
+
+// FrameReader 从字节流中按长度前缀读取完整的消息帧，可以应对TCP的半包与粘包
+type FrameReader struct {
+	MaxFrameLen uint32 // 单帧最大长度，为0时使用DefaultMaxFrameLen
+
+	r      io.Reader
+	header [frameHeaderLen]byte
+}
+
+// NewFrameReader 创建帧读取器
+func NewFrameReader(r io.Reader) *FrameReader {
+	return &FrameReader{r: r}
+}
+
+// ReadFrame 读取一帧，返回消息类型和包体
+// 流在帧边界处结束时返回io.EOF，帧读到一半结束时返回ErrShortFrame
+func (fr *FrameReader) ReadFrame() (uint16, []byte, error) {
+	if _, err := io.ReadFull(fr.r, fr.header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("%w: 帧头", ErrShortFrame)
+		}
+		return 0, nil, err
+	}
+
+	length := binary.LittleEndian.Uint32(fr.header[0:4])
+	if repeat := binary.LittleEndian.Uint32(fr.header[4:8]); repeat != length {
+		return 0, nil, fmt.Errorf("%w: %d != %d", ErrLengthMismatch, length, repeat)
+	}
+	if length < HeadLen+MsgTypeLen+KeepLen {
+		return 0, nil, fmt.Errorf("%w: 长度字段 %d", ErrShortFrame, length)
+	}
+	maxLen := fr.MaxFrameLen
+	if maxLen == 0 {
+		maxLen = DefaultMaxFrameLen
+	}
+	if length > maxLen {
+		return 0, nil, fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, length, maxLen)
+	}
+	msgType := binary.LittleEndian.Uint16(fr.header[8:10])
+	if msgType != ClientMsgType && msgType != ServerMsgType {
+		return 0, nil, fmt.Errorf("%w: %d", ErrMsgType, msgType)
+	}
+
+	// 包体
+	body := make([]byte, length-(HeadLen+MsgTypeLen+KeepLen))
+	if _, err := io.ReadFull(fr.r, body); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("%w: 包体", ErrShortFrame)
+		}
+		return 0, nil, err
+	}
+	return msgType, body, nil
+}
+
+// EncodeFrame 按斗鱼协议为包体加上帧头
+func EncodeFrame(msgType uint16, body []byte) []byte {
+	frame := make([]byte, frameHeaderLen, int(frameHeaderLen)+len(body))
+	length := uint32(len(frame)+len(body)) - HeadLen
+	binary.LittleEndian.PutUint32(frame[0:4], length)
+	binary.LittleEndian.PutUint32(frame[4:8], length)
+	binary.LittleEndian.PutUint16(frame[8:10], msgType)
+	binary.LittleEndian.PutUint16(frame[10:12], 0)
+	return append(frame, body...)
+}
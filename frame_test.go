@@ -0,0 +1,149 @@
+package douyulive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+type testFrame struct {
+	msgType uint16
+	body    string
+}
+
+func TestFrameReader_ReadFrame(t *testing.T) {
+	chat := EncodeFrame(ServerMsgType, []byte("type@=chatmsg/txt@=hi/\x00"))
+	login := EncodeFrame(ServerMsgType, []byte("type@=loginres/\x00"))
+
+	mismatch := EncodeFrame(ServerMsgType, []byte("type@=chatmsg/\x00"))
+	binary.LittleEndian.PutUint32(mismatch[4:8], 1)
+
+	badType := EncodeFrame(ServerMsgType, []byte("type@=chatmsg/\x00"))
+	binary.LittleEndian.PutUint16(badType[8:10], 123)
+
+	tooSmall := EncodeFrame(ServerMsgType, nil)
+	binary.LittleEndian.PutUint32(tooSmall[0:4], 4)
+	binary.LittleEndian.PutUint32(tooSmall[4:8], 4)
+
+	tests := []struct {
+		name        string
+		input       []byte
+		maxFrameLen uint32
+		want        []testFrame
+		wantErr     error
+	}{
+		{
+			name:    "空流",
+			input:   nil,
+			wantErr: io.EOF,
+		},
+		{
+			name:    "单帧",
+			input:   chat,
+			want:    []testFrame{{ServerMsgType, "type@=chatmsg/txt@=hi/\x00"}},
+			wantErr: io.EOF,
+		},
+		{
+			name:  "粘包",
+			input: append(append([]byte{}, login...), chat...),
+			want: []testFrame{
+				{ServerMsgType, "type@=loginres/\x00"},
+				{ServerMsgType, "type@=chatmsg/txt@=hi/\x00"},
+			},
+			wantErr: io.EOF,
+		},
+		{
+			name:    "空包体",
+			input:   EncodeFrame(ClientMsgType, nil),
+			want:    []testFrame{{ClientMsgType, ""}},
+			wantErr: io.EOF,
+		},
+		{
+			name:    "帧头不完整",
+			input:   chat[:7],
+			wantErr: ErrShortFrame,
+		},
+		{
+			name:    "包体不完整",
+			input:   chat[:len(chat)-1],
+			wantErr: ErrShortFrame,
+		},
+		{
+			name:    "第二帧不完整",
+			input:   append(append([]byte{}, login...), chat[:15]...),
+			want:    []testFrame{{ServerMsgType, "type@=loginres/\x00"}},
+			wantErr: ErrShortFrame,
+		},
+		{
+			name:    "长度字段不一致",
+			input:   mismatch,
+			wantErr: ErrLengthMismatch,
+		},
+		{
+			name:    "长度字段过小",
+			input:   tooSmall,
+			wantErr: ErrShortFrame,
+		},
+		{
+			name:    "消息类型错误",
+			input:   badType,
+			wantErr: ErrMsgType,
+		},
+		{
+			name:        "超过最大长度",
+			input:       chat,
+			maxFrameLen: 16,
+			wantErr:     ErrFrameTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 在每一个可能的位置切分字节流，模拟TCP半包
+			for i := 0; i <= len(tt.input); i++ {
+				r := io.MultiReader(bytes.NewReader(tt.input[:i]), bytes.NewReader(tt.input[i:]))
+				checkFrames(t, r, tt.maxFrameLen, tt.want, tt.wantErr)
+			}
+			checkFrames(t, iotest.OneByteReader(bytes.NewReader(tt.input)), tt.maxFrameLen, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func checkFrames(t *testing.T, r io.Reader, maxFrameLen uint32, want []testFrame, wantErr error) {
+	t.Helper()
+
+	fr := NewFrameReader(r)
+	fr.MaxFrameLen = maxFrameLen
+
+	var got []testFrame
+	for {
+		msgType, body, err := fr.ReadFrame()
+		if err != nil {
+			if !errors.Is(err, wantErr) {
+				t.Fatalf("ReadFrame() error = %v, want %v", err, wantErr)
+			}
+			break
+		}
+		got = append(got, testFrame{msgType, string(body)})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadFrame() frames = %q, want %q", got, want)
+	}
+}
+
+func TestEncodeFrame(t *testing.T) {
+	frame := EncodeFrame(ClientMsgType, []byte("type@=mrkl/\x00"))
+	if got, want := binary.LittleEndian.Uint32(frame[0:4]), uint32(len(frame))-HeadLen; got != want {
+		t.Fatalf("length = %d, want %d", got, want)
+	}
+	if got := binary.LittleEndian.Uint16(frame[8:10]); got != ClientMsgType {
+		t.Fatalf("msgType = %d, want %d", got, ClientMsgType)
+	}
+	if !bytes.Equal(frame, MsgToByte(map[string]string{"type": "mrkl"})) {
+		t.Fatalf("EncodeFrame() = %v, want MsgToByte()", frame)
+	}
+}
@@ -0,0 +1,145 @@
+package douyulive
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"douyu-barrage/stt"
+)
+
+// 消息handler，模型按stt标签解析
+type messageHandler struct {
+	model reflect.Type // 模型类型，handler第二个参数的元素类型
+	fn    reflect.Value
+}
+
+// 按消息类型注册的handler，内置类型的handler先于通过Handle注册的handler调用
+type handlerRegistry struct {
+	mu       sync.RWMutex
+	builtins map[string]*messageHandler // Live上的内置handler字段，nil表示内置类型未设置handler
+	handlers map[string][]messageHandler
+}
+
+// 设置内置类型的handler，重复设置时替换
+func (r *handlerRegistry) setBuiltin(msgType string, handler *messageHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.builtins == nil {
+		r.builtins = make(map[string]*messageHandler)
+	}
+	r.builtins[msgType] = handler
+}
+
+func (r *handlerRegistry) add(msgType string, handler messageHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.handlers == nil {
+		r.handlers = make(map[string][]messageHandler)
+	}
+	r.handlers[msgType] = append(r.handlers[msgType], handler)
+}
+
+// 用decode解析消息并按注册顺序调用handler，decode返回false时跳过该模型类型的handler，返回消息类型是否为内置类型或已注册
+func (r *handlerRegistry) dispatch(roomID int, msgType string, fields map[string]string, decode func(msg interface{}) bool) bool {
+	r.mu.RLock()
+	builtin, isBuiltin := r.builtins[msgType]
+	handlers, ok := r.handlers[msgType]
+	r.mu.RUnlock()
+
+	if builtin != nil {
+		handlers = append([]messageHandler{*builtin}, handlers...)
+	}
+	roomIDValue := reflect.ValueOf(roomID)
+	// 同一模型类型每条消息只解析一次，解析失败的类型记为无效值，每个handler收到一份副本
+	decoded := make(map[reflect.Type]reflect.Value, len(handlers))
+	for _, handler := range handlers {
+		model, exist := decoded[handler.model]
+		if !exist {
+			model = reflect.New(handler.model)
+			if !decode(model.Interface()) {
+				model = reflect.Value{}
+			}
+			decoded[handler.model] = model
+		}
+		if !model.IsValid() {
+			continue
+		}
+		msg := reflect.New(handler.model)
+		msg.Elem().Set(model.Elem())
+		handler.fn.Call([]reflect.Value{roomIDValue, msg})
+	}
+	return ok || isBuiltin
+}
+
+// Handle 注册消息类型的handler，handler的类型必须为func(int, *T)，
+// 第一个参数为房间ID，T为带stt标签的模型结构体，消息按stt标签解析后传入，
+// 同一消息类型可注册多个handler，按注册顺序调用，内置消息类型在Live上对应的handler字段之后调用，如：
+//
+//	live.Handle("blab", func(roomID int, msg *FanBadgeUpgrade) {})
+func (live *Live) Handle(msgType string, handler interface{}) error {
+	if msgType == "" {
+		return fmt.Errorf("消息类型不能为空")
+	}
+	h, err := newMessageHandler(handler)
+	if err != nil {
+		return fmt.Errorf("消息类型 %s: %w", msgType, err)
+	}
+	live.handlers.add(msgType, *h)
+	return nil
+}
+
+var intType = reflect.TypeOf(0)
+
+func newMessageHandler(handler interface{}) (*messageHandler, error) {
+	fn := reflect.ValueOf(handler)
+	if fn.Kind() != reflect.Func || fn.IsNil() {
+		return nil, fmt.Errorf("handler必须为非nil的函数，实际为 %T", handler)
+	}
+	t := fn.Type()
+	if t.NumIn() != 2 || t.NumOut() != 0 || t.In(0) != intType ||
+		t.In(1).Kind() != reflect.Ptr || t.In(1).Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("handler的类型必须为func(int, *T)，T为结构体，实际为 %s", t)
+	}
+	return &messageHandler{model: t.In(1).Elem(), fn: fn}, nil
+}
+
+// 按stt标签将消息解析到msg中，StrictDecode时值无法转换会通过ErrorHandler通知并返回false
+func (live *Live) decode(roomID int, msgType string, fields map[string]string, msg interface{}) bool {
+	if !live.StrictDecode {
+		transferMessage(fields, msg)
+		return true
+	}
+	if err := (&stt.Decoder{Strict: true}).UnmarshalMap(fields, msg); err != nil {
+		live.reportError(roomID, fmt.Errorf("消息类型 %s 解析失败: %w", msgType, err))
+		return false
+	}
+	return true
+}
+
+// 内置消息类型与Live上对应的handler字段，模型类型由字段的函数类型决定
+var builtinHandlers = []struct {
+	msgType string
+	handler func(live *Live) interface{}
+}{
+	{LoginRespType, func(live *Live) interface{} { return live.LoginRespMessageHandler }},
+	{ErrorRespType, func(live *Live) interface{} { return live.ErrorMessageHandler }},
+	{BarrageRespType, func(live *Live) interface{} { return live.BarrageMessageHandler }},
+	{StormRespType, func(live *Live) interface{} { return live.StormMessageHandler }},
+	{SendGiftRespType, func(live *Live) interface{} { return live.SendGiftMessageHandler }},
+	{SpecialUserRespType, func(live *Live) interface{} { return live.SpecialUserMessageHandler }},
+	{SwitchBroadcastRespType, func(live *Live) interface{} { return live.SwitchBroadcastMessageHandler }},
+	{BroadcastRankRespType, func(live *Live) interface{} { return live.BroadcastRankMessageHandler }},
+	{SuperBarrageRespType, func(live *Live) interface{} { return live.SuperBarrageMessageHandler }},
+	{RoomGiftBroadcastRespType, func(live *Live) interface{} { return live.RoomGiftBarrageMessageHandler }},
+}
+
+// 将Live上的内置handler字段注册到handlerRegistry，Start时调用，之后修改字段不再生效
+// 未设置handler的内置类型同样登记，不视为未知类型
+func (live *Live) registerBuiltinHandlers() {
+	for _, builtin := range builtinHandlers {
+		// 字段为nil时返回错误，handler为nil
+		handler, _ := newMessageHandler(builtin.handler(live))
+		live.handlers.setBuiltin(builtin.msgType, handler)
+	}
+}
@@ -0,0 +1,164 @@
+package douyulive
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"douyu-barrage/stt"
+)
+
+// 粉丝牌升级消息
+type fanBadgeUpgrade struct {
+	Type          string `stt:"type"`
+	UID           int64  `stt:"uid"`
+	NickName      string `stt:"nn"`
+	BadgeLevel    int64  `stt:"bl"`
+	BadgeNickName string `stt:"bnn"`
+}
+
+func TestLive_Handle(t *testing.T) {
+	live := &Live{}
+	var got []string
+	if err := live.Handle("blab", func(roomID int, msg *fanBadgeUpgrade) {
+		got = append(got, "first")
+		want := &fanBadgeUpgrade{Type: "blab", UID: 1, NickName: "甲", BadgeLevel: 4, BadgeNickName: "粉丝"}
+		if roomID != 10 || !reflect.DeepEqual(msg, want) {
+			t.Errorf("roomID = %d, msg = %+v", roomID, msg)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := live.Handle("blab", func(roomID int, msg *struct {
+		Level int64 `stt:"bl"`
+	}) {
+		got = append(got, "second")
+		if msg.Level != 4 {
+			t.Errorf("level = %d", msg.Level)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var unknown []string
+	live.UnknownMessageHandler = func(roomID int, msgType string, fields map[string]string) {
+		unknown = append(unknown, msgType)
+	}
+	live.dispatch(&socketMessage{roomID: 10, body: map[string]string{"type": "blab", "uid": "1", "nn": "甲", "bl": "4", "bnn": "粉丝"}})
+	live.dispatch(&socketMessage{roomID: 10, body: map[string]string{"type": "anbc"}})
+
+	if strings.Join(got, ",") != "first,second" {
+		t.Errorf("handlers = %v", got)
+	}
+	if strings.Join(unknown, ",") != "anbc" {
+		t.Errorf("unknown = %v", unknown)
+	}
+}
+
+func TestLive_HandleBuiltin(t *testing.T) {
+	var barrages, extra int
+	live := &Live{
+		BarrageMessageHandler: func(roomID int, msg *BarrageMessageModel) {
+			barrages++
+			if msg.Txt != "hi" {
+				t.Errorf("txt = %q", msg.Txt)
+			}
+		},
+	}
+	if err := live.Handle(BarrageRespType, func(roomID int, msg *BarrageMessageModel) {
+		extra++
+		if barrages != 1 {
+			t.Error("Handle注册的handler先于内置handler调用")
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	live.registerBuiltinHandlers()
+	// 重复注册不会重复调用内置handler
+	live.registerBuiltinHandlers()
+
+	var unknown []string
+	live.UnknownMessageHandler = func(roomID int, msgType string, fields map[string]string) {
+		unknown = append(unknown, msgType)
+	}
+	live.dispatch(&socketMessage{roomID: 1, body: map[string]string{"type": BarrageRespType, "txt": "hi"}})
+	// 未设置handler的内置类型不是未知类型
+	live.dispatch(&socketMessage{roomID: 1, body: map[string]string{"type": SendGiftRespType}})
+
+	if barrages != 1 || extra != 1 {
+		t.Errorf("barrages = %d, extra = %d", barrages, extra)
+	}
+	if len(unknown) != 0 {
+		t.Errorf("unknown = %v", unknown)
+	}
+}
+
+func TestLive_HandleInvalid(t *testing.T) {
+	var nilHandler func(int, *BarrageMessageModel)
+	tests := []struct {
+		name    string
+		msgType string
+		handler interface{}
+	}{
+		{"empty type", "", func(int, *BarrageMessageModel) {}},
+		{"nil", "blab", nil},
+		{"typed nil", "blab", nilHandler},
+		{"not func", "blab", 1},
+		{"no room id", "blab", func(*BarrageMessageModel) {}},
+		{"not pointer", "blab", func(int, BarrageMessageModel) {}},
+		{"not struct", "blab", func(int, *string) {}},
+		{"string room id", "blab", func(string, *BarrageMessageModel) {}},
+		{"result", "blab", func(int, *BarrageMessageModel) error { return nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			live := &Live{}
+			if err := live.Handle(tt.msgType, tt.handler); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestLive_StrictDecode(t *testing.T) {
+	fields := map[string]string{"type": BarrageRespType, "uid": "abc", "txt": "hi"}
+	for _, strict := range []bool{false, true} {
+		var (
+			barrages []*BarrageMessageModel
+			errs     []error
+		)
+		live := &Live{
+			StrictDecode: strict,
+			BarrageMessageHandler: func(roomID int, msg *BarrageMessageModel) {
+				barrages = append(barrages, msg)
+			},
+			ErrorHandler: func(roomID int, err error) {
+				errs = append(errs, err)
+			},
+		}
+		// 同一模型类型的多个handler只解析一次，错误只通知一次
+		if err := live.Handle(BarrageRespType, func(roomID int, msg *BarrageMessageModel) {
+			barrages = append(barrages, msg)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		live.registerBuiltinHandlers()
+		live.dispatch(&socketMessage{roomID: 1, body: fields})
+
+		if !strict {
+			// 默认无法转换的值保留零值
+			if len(barrages) != 2 || barrages[0] == barrages[1] || barrages[1].UID != 0 || barrages[1].Txt != "hi" || len(errs) != 0 {
+				t.Errorf("宽松解析 barrages = %v, errs = %v", barrages, errs)
+			}
+			continue
+		}
+		if len(barrages) != 0 {
+			t.Errorf("严格解析失败仍通知了handler: %v", barrages)
+		}
+		var convErr *stt.ConversionError
+		if len(errs) != 1 || !errors.As(errs[0], &convErr) || convErr.Key != "uid" {
+			t.Errorf("严格解析 errs = %v", errs)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package douyulive
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// HeartbeatType 心跳消息类型，客户端与服务器都使用
+const HeartbeatType = "mrkl"
+
+// DefaultHeartbeatInterval 斗鱼要求的心跳间隔
+const DefaultHeartbeatInterval = 45 * time.Second
+
+// ErrHeartbeatTimeout 超过HeartbeatTimeout没有收到任何消息
+var ErrHeartbeatTimeout = errors.New("心跳超时，连接已失效")
+
+// 心跳间隔
+func (live *Live) heartbeatInterval() time.Duration {
+	if live.HeartbeatInterval > 0 {
+		return live.HeartbeatInterval
+	}
+	return DefaultHeartbeatInterval
+}
+
+// 没有收到消息的超时时间，默认为两个心跳间隔，小于0时不检测
+func (live *Live) heartbeatTimeout() time.Duration {
+	if live.HeartbeatTimeout != 0 {
+		return live.HeartbeatTimeout
+	}
+	return 2 * live.heartbeatInterval()
+}
+
+// 心跳，按间隔发送mrkl，写入失败时返回
+func (room *liveRoom) heartBeat(ctx context.Context, conn net.Conn) error {
+	interval := room.heartbeatInterval
+	if interval <= 0 {
+		interval = DefaultHeartbeatInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// 上一个连接未回复的心跳不计入往返时间
+	atomic.StoreInt64(&room.pingSent, 0)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		_ = conn.SetWriteDeadline(now.Add(interval))
+		if _, err := conn.Write(MsgToByte(map[string]string{
+			"type": HeartbeatType,
+		})); err != nil {
+			room.log.warn("发送心跳失败", "err", err)
+			room.metrics.HeartbeatFailure(room.roomID)
+			return err
+		}
+		_ = conn.SetWriteDeadline(time.Time{})
+		// 只记录还没有回复的第一次心跳，避免服务器不回复时得到错误的往返时间
+		atomic.CompareAndSwapInt64(&room.pingSent, 0, now.UnixNano())
+	}
+}
+
+// 收到服务器的心跳回复，记录往返时间
+func (room *liveRoom) pong(now time.Time) {
+	sent := atomic.SwapInt64(&room.pingSent, 0)
+	if sent == 0 {
+		return
+	}
+	atomic.StoreInt64(&room.rtt, now.UnixNano()-sent)
+}
+
+// 最近一次心跳的往返时间，没有收到过心跳回复时为0
+func (room *liveRoom) lastRTT() time.Duration {
+	return time.Duration(atomic.LoadInt64(&room.rtt))
+}
+
+// 最后收到消息的时间
+func (room *liveRoom) lastFrameTime() time.Time {
+	if t := atomic.LoadInt64(&room.lastFrame); t != 0 {
+		return time.Unix(0, t)
+	}
+	return time.Time{}
+}
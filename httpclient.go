@@ -0,0 +1,78 @@
+package douyulive
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// DefaultHTTPTimeout 请求开放平台的默认超时时间
+const DefaultHTTPTimeout = 10 * time.Second
+
+// DefaultHTTPClient 请求开放平台默认使用的HTTP客户端，校验服务器证书并复用连接
+var DefaultHTTPClient = &http.Client{Timeout: DefaultHTTPTimeout}
+
+// NewHTTPClient 创建使用rootCAs校验服务器证书的HTTP客户端，用于代理替换了证书等需要自定义CA的场景，
+// rootCAs为nil时使用系统CA
+func NewHTTPClient(rootCAs *x509.CertPool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	return &http.Client{Transport: transport, Timeout: DefaultHTTPTimeout}
+}
+
+// RetryPolicy 请求开放平台失败后的重试策略，只重试网络错误与限流等临时错误
+type RetryPolicy struct {
+	MaxRetries     int           // 最多重试次数，默认2，小于0表示不重试
+	InitialBackoff time.Duration // 第一次重试前的等待时间，默认200ms
+	MaxBackoff     time.Duration // 等待时间上限，默认2s
+}
+
+const (
+	defaultMaxRetries          = 2
+	defaultRetryInitialBackoff = 200 * time.Millisecond
+	defaultRetryMaxBackoff     = 2 * time.Second
+)
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxRetries == 0 {
+		p.MaxRetries = defaultMaxRetries
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = defaultRetryInitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultRetryMaxBackoff
+	}
+	return p
+}
+
+// 按策略执行fn，fn返回可重试的错误时等待后重试
+func (p RetryPolicy) do(ctx context.Context, fn func() error) error {
+	p = p.withDefaults()
+	backoff := ReconnectPolicy{InitialBackoff: p.InitialBackoff, MaxBackoff: p.MaxBackoff}.withDefaults()
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxRetries || !retryable(ctx, err) {
+			return err
+		}
+		if !sleepContext(ctx, backoff.backoff(attempt+1)) {
+			return err
+		}
+	}
+}
+
+// 网络错误与临时的接口错误可以重试
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package douyulive
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachedTokenProvider_Retry(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int32 // 前几次请求失败
+		status    int
+		body      string
+		wantCalls int32
+		wantErr   bool
+	}{
+		{"temporary", 2, http.StatusServiceUnavailable, `unavailable`, 3, false},
+		{"rate limited", 1, http.StatusOK, `{"code":429,"msg":"too many requests"}`, 2, false},
+		{"exhausted", 5, http.StatusBadGateway, `bad gateway`, 3, true},
+		{"bad signature", 5, http.StatusOK, `{"code":401,"msg":"auth error"}`, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) <= tt.failures {
+					w.WriteHeader(tt.status)
+					_, _ = w.Write([]byte(tt.body))
+					return
+				}
+				_, _ = w.Write([]byte(`{"code":0,"msg":"ok","data":{"token":"token","expire":7200}}`))
+			}))
+			defer server.Close()
+
+			provider := &CachedTokenProvider{
+				BaseURL: server.URL,
+				Retry:   RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond},
+			}
+			token, err := provider.Token(context.Background(), "aid", "secret")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("token = %q, err = %v", token, err)
+			}
+			if n := atomic.LoadInt32(&calls); n != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCachedTokenProvider_NoRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	provider := &CachedTokenProvider{BaseURL: server.URL, Retry: RetryPolicy{MaxRetries: -1}}
+	if _, err := provider.Token(context.Background(), "aid", "secret"); err == nil {
+		t.Fatal("expected error")
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("calls = %d, want 1", n)
+	}
+}
+
+func TestHTTPClient_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":0,"msg":"ok","data":{"token":"token","expire":7200}}`))
+	}))
+	defer server.Close()
+
+	// 默认校验证书，自签名证书应失败
+	_, err := requestTokenInfo(context.Background(), nil, server.URL, "aid", "secret", time.Now())
+	var certErr x509.UnknownAuthorityError
+	if !errors.As(err, &certErr) {
+		t.Fatalf("err = %v, want x509.UnknownAuthorityError", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	info, err := requestTokenInfo(context.Background(), NewHTTPClient(pool), server.URL, "aid", "secret", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Token != "token" {
+		t.Fatalf("token = %q", info.Token)
+	}
+}
+
+func TestHTTPClient_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Timeout: 20 * time.Millisecond}
+	_, err := requestTokenInfo(context.Background(), client, server.URL, "aid", "secret", time.Now())
+	if err == nil {
+		t.Fatal("expected timeout")
+	}
+	// 错误中不能包含签名等查询参数
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) || !urlErr.Timeout() {
+		t.Fatalf("err = %v, want timeout *url.Error", err)
+	}
+	if strings.Contains(err.Error(), "auth=") || strings.Contains(err.Error(), "aid=") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package douyulive
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// JoinError Join中加入失败的房间及原因，其余房间已正常加入
+// errors.Is与errors.As会依次检查每个房间的错误
+type JoinError struct {
+	Errors map[int]error // 按房间ID
+}
+
+// Rooms 加入失败的房间ID，从小到大排序
+func (e *JoinError) Rooms() []int {
+	ids := make([]int, 0, len(e.Errors))
+	for id := range e.Errors {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (e *JoinError) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, id := range e.Rooms() {
+		parts = append(parts, fmt.Sprintf("房间 %d: %s", id, e.Errors[id]))
+	}
+	return "加入房间失败: " + strings.Join(parts, "; ")
+}
+
+// Is 任一房间的错误匹配target时返回true
+func (e *JoinError) Is(target error) bool {
+	for _, id := range e.Rooms() {
+		if errors.Is(e.Errors[id], target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As 将第一个匹配的房间错误赋值给target
+func (e *JoinError) As(target interface{}) bool {
+	for _, id := range e.Rooms() {
+		if errors.As(e.Errors[id], target) {
+			return true
+		}
+	}
+	return false
+}
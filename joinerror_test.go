@@ -0,0 +1,125 @@
+package douyulive
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLive_JoinPartialFailure(t *testing.T) {
+	addr := listenDiscard(t)
+	live := &Live{TokenProvider: testToken}
+	live.Start(context.Background())
+	defer live.Close(context.Background())
+
+	if err := live.Join("aid", "secret", testServers(addr), 1); err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+	err := live.Join("aid", "secret", testServers(addr), 1, 2, 2)
+	var joinErr *JoinError
+	if !errors.As(err, &joinErr) {
+		t.Fatalf("Join() error = %v, want *JoinError", err)
+	}
+	if rooms := joinErr.Rooms(); !reflect.DeepEqual(rooms, []int{1}) {
+		t.Fatalf("JoinError.Rooms() = %v, want [1]", rooms)
+	}
+	if !errors.Is(err, ErrRoomExists) {
+		t.Fatalf("errors.Is(%v, ErrRoomExists) = false", err)
+	}
+	if rooms := live.Rooms(); !reflect.DeepEqual(rooms, []int{1, 2}) {
+		t.Fatalf("Rooms() = %v, want [1 2]", rooms)
+	}
+}
+
+func TestJoinError(t *testing.T) {
+	err := error(&JoinError{Errors: map[int]error{
+		3: ErrLoginTimeout,
+		1: &ServerError{RoomID: 1, Code: ServerCodeInvalidRoom},
+	}})
+
+	if !errors.Is(err, ErrLoginTimeout) || !errors.Is(err, ErrInvalidRoomID) || errors.Is(err, ErrClosed) {
+		t.Fatalf("errors.Is mismatch: %v", err)
+	}
+	var serverErr *ServerError
+	if !errors.As(err, &serverErr) || serverErr.RoomID != 1 {
+		t.Fatalf("errors.As = %+v", serverErr)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "房间 1: ") || strings.Index(msg, "房间 1") > strings.Index(msg, "房间 3") {
+		t.Fatalf("Error() = %q", msg)
+	}
+}
+
+func TestLive_ErrorHandler(t *testing.T) {
+	// 第一次登录后立即断开连接
+	ln := listenLogin(t, func(conn net.Conn, count int) {
+		if count == 1 {
+			_ = conn.Close()
+		}
+	})
+
+	errs := make(chan error, 10)
+	live := &Live{
+		TokenProvider: testToken,
+		Reconnect:     ReconnectPolicy{InitialBackoff: 10 * time.Millisecond},
+		ErrorHandler: func(roomID int, err error) {
+			errs <- err
+		},
+	}
+	live.Start(context.Background())
+	defer live.Close(context.Background())
+
+	if err := live.Join("aid", "secret", testServers(ln.Addr()), 1); err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("ErrorHandler() err = nil")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("连接断开没有通知ErrorHandler")
+	}
+}
+
+func TestLive_HandlerPanic(t *testing.T) {
+	errs := make(chan error, 1)
+	received := make(chan string, 2)
+	live := &Live{
+		BarrageMessageHandler: func(roomID int, msg *BarrageMessageModel) {
+			if msg.Txt == "panic" {
+				panic("boom")
+			}
+			received <- msg.Txt
+		},
+		ErrorHandler: func(roomID int, err error) {
+			errs <- err
+		},
+	}
+	live.Start(context.Background())
+	defer live.Close(context.Background())
+
+	server := joinPipeRoom(live, 1)
+	_ = server.send(map[string]string{"type": BarrageRespType, "txt": "panic"})
+	_ = server.send(map[string]string{"type": BarrageRespType, "txt": "ok"})
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrHandlerPanic) || !strings.Contains(err.Error(), "boom") {
+			t.Fatalf("ErrorHandler() err = %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("handler panic没有通知ErrorHandler")
+	}
+	select {
+	case txt := <-received:
+		if txt != "ok" {
+			t.Fatalf("handler got %q, want ok", txt)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("panic后消息分析协程已退出")
+	}
+}
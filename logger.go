@@ -0,0 +1,205 @@
+package douyulive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogLevel 日志级别
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota // 调试信息，仅Debug为true时输出
+	LevelInfo                  // 连接、重连等状态变化
+	LevelWarn                  // 可自动恢复的错误
+	LevelError                 // 无法恢复的错误
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("LogLevel(%d)", int(l))
+	}
+}
+
+// Logger 日志接口，keyvals为交替的键和值，如 "room", 288016, "server", "host:port"
+type Logger interface {
+	Log(level LogLevel, msg string, keyvals ...interface{})
+}
+
+// 日志中会被隐藏值的键
+var redactedKeys = map[string]bool{
+	"token":  true,
+	"auth":   true,
+	"secret": true,
+}
+
+const redacted = "***"
+
+// NopLogger 不输出任何日志
+type NopLogger struct{}
+
+// Log 丢弃日志
+func (NopLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {}
+
+// NewStdLogger 使用标准库log输出，格式为 [level] msg key=value ...，低于minLevel的日志不输出，l为nil时输出到标准错误
+func NewStdLogger(l *log.Logger, minLevel LogLevel) Logger {
+	if l == nil {
+		l = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	return &stdLogger{logger: l, minLevel: minLevel}
+}
+
+type stdLogger struct {
+	logger   *log.Logger
+	minLevel LogLevel
+}
+
+func (l *stdLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	if level < l.minLevel {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s", level, msg)
+	keyvals = redact(keyvals)
+	for i := 0; i < len(keyvals); i += 2 {
+		fmt.Fprintf(&b, " %s=%v", logKey(keyvals[i]), logValue(keyvals, i+1))
+	}
+	l.logger.Println(b.String())
+}
+
+// NewJSONLogger 每条日志输出一行JSON，包含time、level、msg与所有键值，低于minLevel的日志不输出
+func NewJSONLogger(w io.Writer, minLevel LogLevel) Logger {
+	return &jsonLogger{w: w, minLevel: minLevel}
+}
+
+type jsonLogger struct {
+	mu       sync.Mutex
+	w        io.Writer
+	minLevel LogLevel
+}
+
+func (l *jsonLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	if level < l.minLevel {
+		return
+	}
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	writeJSONValue(&b, time.Now().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSONValue(&b, level.String())
+	b.WriteString(`,"msg":`)
+	writeJSONValue(&b, msg)
+	keyvals = redact(keyvals)
+	for i := 0; i < len(keyvals); i += 2 {
+		b.WriteByte(',')
+		writeJSONValue(&b, logKey(keyvals[i]))
+		b.WriteByte(':')
+		writeJSONValue(&b, logValue(keyvals, i+1))
+	}
+	b.WriteString("}\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(b.Bytes())
+}
+
+func writeJSONValue(b *bytes.Buffer, v interface{}) {
+	switch value := v.(type) {
+	case error:
+		v = value.Error()
+	case fmt.Stringer:
+		v = value.String()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
+
+func logKey(key interface{}) string {
+	if s, ok := key.(string); ok {
+		return s
+	}
+	return fmt.Sprint(key)
+}
+
+// 键值不成对时最后一个键的值
+func logValue(keyvals []interface{}, i int) interface{} {
+	if i < len(keyvals) {
+		return keyvals[i]
+	}
+	return "(MISSING)"
+}
+
+// 隐藏token、auth、secret的值，返回新的切片
+func redact(keyvals []interface{}) []interface{} {
+	var out []interface{}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if key, ok := keyvals[i].(string); ok && redactedKeys[strings.ToLower(key)] {
+			if out == nil {
+				out = append([]interface{}(nil), keyvals...)
+			}
+			out[i+1] = redacted
+		}
+	}
+	if out == nil {
+		return keyvals
+	}
+	return out
+}
+
+// SDK内部使用的日志，隐藏敏感字段，Debug为false时不输出调试日志，并附加固定的键值
+type sdkLogger struct {
+	logger    Logger
+	showDebug bool
+	fields    []interface{}
+}
+
+func newSDKLogger(logger Logger, debug bool) *sdkLogger {
+	if logger == nil {
+		if debug {
+			logger = NewStdLogger(nil, LevelDebug)
+		} else {
+			logger = NopLogger{}
+		}
+	}
+	return &sdkLogger{logger: logger, showDebug: debug}
+}
+
+// 附加键值，如房间ID
+func (l *sdkLogger) with(keyvals ...interface{}) *sdkLogger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(append(fields, l.fields...), keyvals...)
+	return &sdkLogger{logger: l.logger, showDebug: l.showDebug, fields: fields}
+}
+
+func (l *sdkLogger) log(level LogLevel, msg string, keyvals ...interface{}) {
+	if l == nil || (level == LevelDebug && !l.showDebug) {
+		return
+	}
+	all := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	all = append(append(all, l.fields...), keyvals...)
+	l.logger.Log(level, msg, redact(all)...)
+}
+
+func (l *sdkLogger) debug(msg string, keyvals ...interface{}) { l.log(LevelDebug, msg, keyvals...) }
+func (l *sdkLogger) info(msg string, keyvals ...interface{})  { l.log(LevelInfo, msg, keyvals...) }
+func (l *sdkLogger) warn(msg string, keyvals ...interface{})  { l.log(LevelWarn, msg, keyvals...) }
+func (l *sdkLogger) error(msg string, keyvals ...interface{}) { l.log(LevelError, msg, keyvals...) }
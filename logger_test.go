@@ -0,0 +1,110 @@
+package douyulive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 记录日志，用于检查SDK输出的日志
+type recordLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+type logEntry struct {
+	level   LogLevel
+	msg     string
+	keyvals []interface{}
+}
+
+func (l *recordLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, logEntry{level, msg, keyvals})
+}
+
+func (l *recordLogger) snapshot() []logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]logEntry(nil), l.entries...)
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0), LevelInfo)
+
+	logger.Log(LevelDebug, "不输出")
+	logger.Log(LevelWarn, "创建连接失败", "room", 1, "server", "127.0.0.1:8601", "err", errors.New("refused"), "token", "abc", "odd")
+
+	want := "[warn] 创建连接失败 room=1 server=127.0.0.1:8601 err=refused token=*** odd=(MISSING)\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewJSONLogger(&buf, LevelDebug)
+
+	logger.Log(LevelInfo, "连接创建成功", "room", 288016, "server", "host:80", "auth", "signature")
+	logger.Log(LevelError, "放弃重连", "err", errors.New("超过最大重连次数"), "attempt", 3)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("lines = %q", lines)
+	}
+	var first map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if first["level"] != "info" || first["msg"] != "连接创建成功" || first["room"] != float64(288016) ||
+		first["server"] != "host:80" || first["auth"] != redacted || first["time"] == nil {
+		t.Fatalf("first = %v", first)
+	}
+	var second map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatal(err)
+	}
+	if second["level"] != "error" || second["err"] != "超过最大重连次数" || second["attempt"] != float64(3) {
+		t.Fatalf("second = %v", second)
+	}
+}
+
+func TestLive_Logger(t *testing.T) {
+	for _, debug := range []bool{false, true} {
+		logger := &recordLogger{}
+		live := &Live{Debug: debug, Logger: logger, TokenProvider: StaticTokenProvider("secret-token")}
+		live.Start(context.Background())
+		if err := live.Join("aid", "secret", testServers(listenDiscard(t)), 1); err != nil {
+			t.Fatalf("Join() error = %v", err)
+		}
+		_ = live.Close(context.Background())
+
+		var hasDebug, hasRoom bool
+		for _, entry := range logger.snapshot() {
+			if entry.level == LevelDebug {
+				hasDebug = true
+			}
+			for i := 0; i+1 < len(entry.keyvals); i += 2 {
+				if entry.keyvals[i] == "room" && entry.keyvals[i+1] == 1 {
+					hasRoom = true
+				}
+				if v, ok := entry.keyvals[i+1].(string); ok && (v == "secret-token" || v == "secret") {
+					t.Errorf("日志泄露了 %s: %v", entry.keyvals[i], entry)
+				}
+			}
+		}
+		if hasDebug != debug {
+			t.Errorf("Debug = %v, 输出调试日志 = %v", debug, hasDebug)
+		}
+		if !hasRoom {
+			t.Errorf("日志没有房间ID: %v", logger.snapshot())
+		}
+	}
+}
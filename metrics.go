@@ -0,0 +1,297 @@
+package douyulive
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics 消息接收流程的指标收集，Live.Metrics为nil时不收集
+// 方法会在接收、心跳与消息分析协程中并发调用，实现需要并发安全且不能阻塞
+type Metrics interface {
+	FrameReceived(roomID int, msgType string, bytes int)   // 收到一帧消息，bytes为包体长度
+	ParseError(roomID int, err error)                      // 消息帧解析失败，之后连接会重连
+	MessageDropped(roomID int, msgType string)             // 消息被丢弃，未通知handler
+	QueueDepth(worker int, depth int)                      // 消息放入或取出后消息分析协程队列中的消息数
+	HandlerLatency(msgType string, duration time.Duration) // 一条消息从分析到所有handler返回的耗时
+	Reconnect(roomID int)                                  // 断线后尝试重连
+	HeartbeatFailure(roomID int)                           // 心跳发送失败或超时没有收到消息
+	TokenRefresh(aid string, err error)                    // 请求token接口，err为nil表示成功
+}
+
+// NopMetrics 不收集任何指标
+type NopMetrics struct{}
+
+func (NopMetrics) FrameReceived(int, string, int)       {}
+func (NopMetrics) ParseError(int, error)                {}
+func (NopMetrics) MessageDropped(int, string)           {}
+func (NopMetrics) QueueDepth(int, int)                  {}
+func (NopMetrics) HandlerLatency(string, time.Duration) {}
+func (NopMetrics) Reconnect(int)                        {}
+func (NopMetrics) HeartbeatFailure(int)                 {}
+func (NopMetrics) TokenRefresh(string, error)           {}
+
+// metrics为nil时使用NopMetrics
+func metricsOrNop(metrics Metrics) Metrics {
+	if metrics == nil {
+		return NopMetrics{}
+	}
+	return metrics
+}
+
+// DefaultLatencyBuckets handler耗时直方图默认的桶上限，单位秒
+var DefaultLatencyBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// PrometheusMetrics 在内存中汇总指标，并以Prometheus文本格式输出，不依赖Prometheus客户端库
+// 本身实现了http.Handler，可直接挂载到/metrics：
+//
+//	metrics := douyulive.NewPrometheusMetrics()
+//	live := &douyulive.Live{Metrics: metrics}
+//	http.Handle("/metrics", metrics)
+type PrometheusMetrics struct {
+	mu              sync.Mutex
+	framesReceived  *metricVec
+	bytesReceived   *metricVec
+	parseErrors     *metricVec
+	messagesDropped *metricVec
+	queueDepth      *metricVec
+	reconnects      *metricVec
+	heartbeatFails  *metricVec
+	tokenRefreshes  *metricVec
+	handlerLatency  *histogramVec
+}
+
+// NewPrometheusMetrics 创建指标收集器，指标名以douyu_开头
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		framesReceived:  newMetricVec("douyu_frames_received_total", "收到的消息帧数", "counter", "room", "type"),
+		bytesReceived:   newMetricVec("douyu_bytes_received_total", "收到的消息包体字节数", "counter", "room", "type"),
+		parseErrors:     newMetricVec("douyu_parse_errors_total", "消息帧解析失败次数", "counter", "room"),
+		messagesDropped: newMetricVec("douyu_messages_dropped_total", "丢弃的消息数", "counter", "room", "type"),
+		queueDepth:      newMetricVec("douyu_queue_depth", "消息分析协程队列中等待处理的消息数", "gauge", "worker"),
+		reconnects:      newMetricVec("douyu_reconnects_total", "断线重连次数", "counter", "room"),
+		heartbeatFails:  newMetricVec("douyu_heartbeat_failures_total", "心跳发送失败或超时次数", "counter", "room"),
+		tokenRefreshes:  newMetricVec("douyu_token_refreshes_total", "请求token接口次数", "counter", "result"),
+		handlerLatency:  newHistogramVec("douyu_handler_duration_seconds", "消息handler耗时", DefaultLatencyBuckets, "type"),
+	}
+}
+
+func (m *PrometheusMetrics) FrameReceived(roomID int, msgType string, bytes int) {
+	room := strconv.Itoa(roomID)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.framesReceived.add(1, room, msgType)
+	m.bytesReceived.add(float64(bytes), room, msgType)
+}
+
+func (m *PrometheusMetrics) ParseError(roomID int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.parseErrors.add(1, strconv.Itoa(roomID))
+}
+
+func (m *PrometheusMetrics) MessageDropped(roomID int, msgType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messagesDropped.add(1, strconv.Itoa(roomID), msgType)
+}
+
+func (m *PrometheusMetrics) QueueDepth(worker int, depth int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queueDepth.set(float64(depth), strconv.Itoa(worker))
+}
+
+func (m *PrometheusMetrics) HandlerLatency(msgType string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlerLatency.observe(duration.Seconds(), msgType)
+}
+
+func (m *PrometheusMetrics) Reconnect(roomID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reconnects.add(1, strconv.Itoa(roomID))
+}
+
+func (m *PrometheusMetrics) HeartbeatFailure(roomID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.heartbeatFails.add(1, strconv.Itoa(roomID))
+}
+
+func (m *PrometheusMetrics) TokenRefresh(aid string, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokenRefreshes.add(1, result)
+}
+
+// WriteTo 以Prometheus文本格式输出所有指标
+func (m *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	m.mu.Lock()
+	for _, vec := range []*metricVec{
+		m.framesReceived, m.bytesReceived, m.parseErrors, m.messagesDropped,
+		m.queueDepth, m.reconnects, m.heartbeatFails, m.tokenRefreshes,
+	} {
+		vec.write(&b)
+	}
+	m.handlerLatency.write(&b)
+	m.mu.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP 输出Prometheus文本格式的指标
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = m.WriteTo(w)
+}
+
+// 一组同名的counter或gauge，按标签值区分
+type metricVec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	values map[string]*sample
+}
+
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+func newMetricVec(name, help, kind string, labels ...string) *metricVec {
+	return &metricVec{name: name, help: help, kind: kind, labels: labels, values: make(map[string]*sample)}
+}
+
+func (v *metricVec) get(labelValues []string) *sample {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.values[key]
+	if !ok {
+		s = &sample{labelValues: labelValues}
+		v.values[key] = s
+	}
+	return s
+}
+
+func (v *metricVec) add(delta float64, labelValues ...string) {
+	v.get(labelValues).value += delta
+}
+
+func (v *metricVec) set(value float64, labelValues ...string) {
+	v.get(labelValues).value = value
+}
+
+func (v *metricVec) write(b *strings.Builder) {
+	if len(v.values) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.kind)
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := v.values[key]
+		fmt.Fprintf(b, "%s%s %s\n", v.name, formatLabels(v.labels, s.labelValues, "", ""), formatFloat(s.value))
+	}
+}
+
+// 一组同名的直方图，按标签值区分
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	values  map[string]*histogram
+}
+
+type histogram struct {
+	labelValues []string
+	counts      []uint64 // 每个桶的累计数量
+	count       uint64
+	sum         float64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogram)}
+}
+
+func (v *histogramVec) observe(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	h, ok := v.values[key]
+	if !ok {
+		h = &histogram{labelValues: labelValues, counts: make([]uint64, len(v.buckets))}
+		v.values[key] = h
+	}
+	for i, upper := range v.buckets {
+		if value <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+func (v *histogramVec) write(b *strings.Builder) {
+	if len(v.values) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", v.name, v.help, v.name)
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		h := v.values[key]
+		for i, upper := range v.buckets {
+			fmt.Fprintf(b, "%s_bucket%s %d\n", v.name, formatLabels(v.labels, h.labelValues, "le", formatFloat(upper)), h.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", v.name, formatLabels(v.labels, h.labelValues, "le", "+Inf"), h.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", v.name, formatLabels(v.labels, h.labelValues, "", ""), formatFloat(h.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", v.name, formatLabels(v.labels, h.labelValues, "", ""), h.count)
+	}
+}
+
+// 输出{name="value",...}，extraName不为空时追加一个标签
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, labelEscaper.Replace(values[i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extraName, extraValue))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// 标签值需要转义反斜杠、双引号与换行
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,66 @@
+package douyulive
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusMetrics_WriteTo(t *testing.T) {
+	m := NewPrometheusMetrics()
+	m.FrameReceived(1, "chatmsg", 10)
+	m.FrameReceived(1, "chatmsg", 5)
+	m.FrameReceived(2, `a"b\`, 1)
+	m.QueueDepth(0, 3)
+	m.QueueDepth(0, 1)
+	m.HandlerLatency("chatmsg", 2*time.Millisecond)
+	m.HandlerLatency("chatmsg", 2*time.Second)
+	m.TokenRefresh("aid", nil)
+	m.TokenRefresh("aid", errors.New("失败"))
+
+	var b strings.Builder
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE douyu_frames_received_total counter\n",
+		`douyu_frames_received_total{room="1",type="chatmsg"} 2` + "\n",
+		`douyu_bytes_received_total{room="1",type="chatmsg"} 15` + "\n",
+		`douyu_frames_received_total{room="2",type="a\"b\\"} 1` + "\n",
+		"# TYPE douyu_queue_depth gauge\n",
+		`douyu_queue_depth{worker="0"} 1` + "\n",
+		`douyu_token_refreshes_total{result="ok"} 1` + "\n",
+		`douyu_token_refreshes_total{result="error"} 1` + "\n",
+		"# TYPE douyu_handler_duration_seconds histogram\n",
+		`douyu_handler_duration_seconds_bucket{type="chatmsg",le="0.001"} 0` + "\n",
+		`douyu_handler_duration_seconds_bucket{type="chatmsg",le="0.005"} 1` + "\n",
+		`douyu_handler_duration_seconds_bucket{type="chatmsg",le="5"} 2` + "\n",
+		`douyu_handler_duration_seconds_bucket{type="chatmsg",le="+Inf"} 2` + "\n",
+		`douyu_handler_duration_seconds_sum{type="chatmsg"} 2.002` + "\n",
+		`douyu_handler_duration_seconds_count{type="chatmsg"} 2` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("输出缺少 %q\n%s", want, out)
+		}
+	}
+	// 没有数据的指标不输出
+	if strings.Contains(out, "douyu_parse_errors_total") {
+		t.Errorf("输出了没有数据的指标\n%s", out)
+	}
+}
+
+func TestPrometheusMetrics_ServeHTTP(t *testing.T) {
+	m := NewPrometheusMetrics()
+	m.Reconnect(1)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), `douyu_reconnects_total{room="1"} 1`) {
+		t.Errorf("body = %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,41 @@
+package douyulive
+
+import "testing"
+
+func TestTransferBarrageMessage(t *testing.T) {
+	body := ByteToMsg([]byte("type@=chatmsg/rid@=288016/uid@=10/nn@=a@Ab/txt@=1@S2/level@=abc/eid@=3/sc@=4/\x00"))
+
+	msg := TransferBarrageMessage(body)
+	if msg.Type != BarrageRespType || msg.RoomID != 288016 || msg.UID != 10 {
+		t.Fatalf("TransferBarrageMessage() = %+v", msg)
+	}
+	if msg.NickName != "a@b" || msg.Txt != "1/2" {
+		t.Fatalf("TransferBarrageMessage() 未还原转义: %q %q", msg.NickName, msg.Txt)
+	}
+	if msg.Level != 0 {
+		t.Fatalf("TransferBarrageMessage() Level = %d, want 0", msg.Level)
+	}
+	if msg.El == nil || msg.El.EID != 3 || msg.El.Sc != 4 {
+		t.Fatalf("TransferBarrageMessage() El = %+v", msg.El)
+	}
+}
+
+func TestTransferBroadcastRankMessage(t *testing.T) {
+	body := ByteToMsg([]byte("type@=ranklist/rid@=288016/ts@=1600000000/" +
+		"list_all@=uid@AA=1@ASnickname@AA=a@AScrk@AA=1@AS@Suid@AA=2@ASnickname@AA=b@AScrk@AA=2@AS@S/" +
+		"list_day@=uid@AA=3@ASnickname@AA=c@AScrk@AA=1@AS@S/\x00"))
+
+	msg := TransferBroadcastRankMessage(body)
+	if msg.RoomID != 288016 || msg.Timestamp != 1600000000 {
+		t.Fatalf("TransferBroadcastRankMessage() = %+v", msg)
+	}
+	if len(msg.ListAll) != 2 || msg.ListAll[1].UID != 2 || msg.ListAll[1].NickName != "b" || msg.ListAll[1].CurrentRank != 2 {
+		t.Fatalf("TransferBroadcastRankMessage() ListAll = %+v", msg.ListAll)
+	}
+	if len(msg.List) != 0 {
+		t.Fatalf("TransferBroadcastRankMessage() List = %+v, want empty", msg.List)
+	}
+	if len(msg.ListDay) != 1 || msg.ListDay[0].NickName != "c" {
+		t.Fatalf("TransferBroadcastRankMessage() ListDay = %+v", msg.ListDay)
+	}
+}
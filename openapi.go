@@ -0,0 +1,162 @@
+package douyulive
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 开放平台接口路径
+const (
+	roomInfoEndpoint = "/api/thirdPart/getRoomInfo"
+	barrageEndpoint  = "/api/thirdPart/getBarrage"
+)
+
+// OpenAPIClient 斗鱼开放平台接口客户端，请求使用与token接口相同的md5签名
+type OpenAPIClient struct {
+	Aid           string
+	Secret        string
+	BaseURL       string        // 开放平台地址，默认DefaultOpenAPIBaseURL
+	HTTPClient    *http.Client  // 默认DefaultHTTPClient
+	TokenProvider TokenProvider // 默认为使用BaseURL与HTTPClient的CachedTokenProvider
+	Retry         RetryPolicy   // 请求失败后的重试策略
+
+	once sync.Once
+}
+
+// NewOpenAPIClient 创建使用默认配置的开放平台接口客户端
+func NewOpenAPIClient(aid, secret string) *OpenAPIClient {
+	return &OpenAPIClient{Aid: aid, Secret: secret}
+}
+
+func (c *OpenAPIClient) tokens() TokenProvider {
+	c.once.Do(func() {
+		if c.TokenProvider == nil {
+			c.TokenProvider = &CachedTokenProvider{BaseURL: c.BaseURL, HTTPClient: c.HTTPClient, Retry: c.Retry}
+		}
+	})
+	return c.TokenProvider
+}
+
+// GetRoomInfo 获取直播间信息
+func (c *OpenAPIClient) GetRoomInfo(ctx context.Context, rid int64) (*RoomInfo, error) {
+	params := url.Values{}
+	params.Set("rid", strconv.FormatInt(rid, 10))
+
+	info := new(RoomInfo)
+	if err := c.get(ctx, roomInfoEndpoint, params, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ListBarrage 获取直播间的历史弹幕，pageContext为上一页返回的PageContext，第一页传0
+func (c *OpenAPIClient) ListBarrage(ctx context.Context, rid int64, pageContext int64) (*BarrageInfo, error) {
+	params := url.Values{}
+	params.Set("rid", strconv.FormatInt(rid, 10))
+	params.Set("page_context", strconv.FormatInt(pageContext, 10))
+
+	info := new(BarrageInfo)
+	if err := c.get(ctx, barrageEndpoint, params, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Barrages 返回按页获取历史弹幕的迭代器
+func (c *OpenAPIClient) Barrages(ctx context.Context, rid int64) *BarrageIterator {
+	return &BarrageIterator{ctx: ctx, client: c, rid: rid}
+}
+
+// 请求接口并将data解析到module
+func (c *OpenAPIClient) get(ctx context.Context, endpoint string, params url.Values, module interface{}) error {
+	token, err := c.tokens().Token(ctx, c.Aid, c.Secret)
+	if err != nil {
+		return err
+	}
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultOpenAPIBaseURL
+	}
+
+	return c.Retry.do(ctx, func() error {
+		signed := url.Values{}
+		for k, v := range params {
+			signed[k] = v
+		}
+		signed.Set("aid", c.Aid)
+		signed.Set("token", token)
+		signed.Set("time", strconv.FormatInt(time.Now().Unix(), 10))
+
+		resp, err := httpSend(ctx, c.HTTPClient, signedURL(baseURL, endpoint, signed, c.Secret))
+		if err != nil {
+			return withEndpoint(err, endpoint)
+		}
+		_, err = MarshalDouYuData(resp, module)
+		return withEndpoint(err, endpoint)
+	})
+}
+
+// BarrageIterator 历史弹幕迭代器，按需请求下一页
+//
+//	it := client.Barrages(ctx, rid)
+//	for it.Next() {
+//		barrage := it.Barrage()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type BarrageIterator struct {
+	ctx         context.Context
+	client      *OpenAPIClient
+	rid         int64
+	pageContext int64
+	page        []*BarrageList
+	index       int
+	fetched     bool // 是否已请求过第一页
+	done        bool
+	err         error
+}
+
+// Next 移动到下一条弹幕，没有更多弹幕或出错时返回false
+func (it *BarrageIterator) Next() bool {
+	for it.index >= len(it.page) {
+		if it.done || it.err != nil {
+			return false
+		}
+		if it.fetched && it.pageContext == 0 {
+			it.done = true
+			return false
+		}
+
+		info, err := it.client.ListBarrage(it.ctx, it.rid, it.pageContext)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.fetched = true
+		it.page, it.index = info.List, 0
+		// 页码没有前进时结束，避免死循环
+		if info.PageContext == it.pageContext {
+			it.done = true
+		}
+		it.pageContext = info.PageContext
+	}
+	it.index++
+	return true
+}
+
+// Barrage 当前弹幕
+func (it *BarrageIterator) Barrage() *BarrageList {
+	if it.index == 0 || it.index > len(it.page) {
+		return nil
+	}
+	return it.page[it.index-1]
+}
+
+// Err 迭代过程中的错误
+func (it *BarrageIterator) Err() error {
+	return it.err
+}
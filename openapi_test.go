@@ -0,0 +1,129 @@
+package douyulive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// 模拟开放平台，校验签名后返回直播间信息与分页的历史弹幕
+func openAPIServer(t *testing.T, pages [][]*BarrageList) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		auth := query.Get("auth")
+		query.Del("auth")
+		if want := Md5(fmt.Sprintf("%s?%s%s", r.URL.Path, query.Encode(), "secret")); auth != want {
+			_, _ = w.Write([]byte(`{"code":401,"msg":"auth error"}`))
+			return
+		}
+
+		var data interface{}
+		switch r.URL.Path {
+		case tokenEndpoint:
+			data = TokenInfo{Token: "token", Expire: 7200}
+		case roomInfoEndpoint:
+			if query.Get("token") != "token" {
+				t.Errorf("token = %q", query.Get("token"))
+			}
+			rid, _ := strconv.ParseInt(query.Get("rid"), 10, 64)
+			data = RoomInfo{RoomID: rid, Attendee: 100, RoomName: "房间"}
+		case barrageEndpoint:
+			page, _ := strconv.Atoi(query.Get("page_context"))
+			info := BarrageInfo{Count: int64(len(pages))}
+			if page < len(pages) {
+				info.List = pages[page]
+				if page+1 < len(pages) {
+					info.PageContext = int64(page + 1)
+				}
+			}
+			data = info
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "msg": "ok", "data": data})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenAPIClient_GetRoomInfo(t *testing.T) {
+	server := openAPIServer(t, nil)
+	client := NewOpenAPIClient("aid", "secret")
+	client.BaseURL = server.URL
+
+	info, err := client.GetRoomInfo(context.Background(), 288016)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.RoomID != 288016 || info.Attendee != 100 || info.RoomName != "房间" {
+		t.Fatalf("room info = %+v", info)
+	}
+
+	client = NewOpenAPIClient("aid", "wrong")
+	client.BaseURL = server.URL
+	client.TokenProvider = StaticTokenProvider("token")
+	_, err = client.GetRoomInfo(context.Background(), 288016)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != APICodeBadSignature || apiErr.Endpoint != roomInfoEndpoint {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestOpenAPIClient_Barrages(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages [][]*BarrageList
+		want  []string
+	}{
+		{"empty", nil, nil},
+		{"one page", [][]*BarrageList{{{Content: "a"}, {Content: "b"}}}, []string{"a", "b"}},
+		{"three pages", [][]*BarrageList{{{Content: "a"}}, {{Content: "b"}, {Content: "c"}}, {{Content: "d"}}}, []string{"a", "b", "c", "d"}},
+		{"empty middle page", [][]*BarrageList{{{Content: "a"}}, {}, {{Content: "c"}}}, []string{"a", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := openAPIServer(t, tt.pages)
+			client := NewOpenAPIClient("aid", "secret")
+			client.BaseURL = server.URL
+
+			var got []string
+			it := client.Barrages(context.Background(), 288016)
+			for it.Next() {
+				got = append(got, it.Barrage().Content)
+			}
+			if err := it.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("barrages = %v, want %v", got, tt.want)
+			}
+			if it.Next() {
+				t.Fatal("Next after end = true")
+			}
+		})
+	}
+}
+
+func TestOpenAPIClient_BarragesError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":403,"msg":"forbidden"}`))
+	}))
+	defer server.Close()
+
+	client := &OpenAPIClient{Aid: "aid", Secret: "secret", BaseURL: server.URL, TokenProvider: StaticTokenProvider("token")}
+	it := client.Barrages(context.Background(), 288016)
+	if it.Next() {
+		t.Fatal("Next = true")
+	}
+	if !errors.Is(it.Err(), ErrForbidden) {
+		t.Fatalf("err = %v", it.Err())
+	}
+}
@@ -1,11 +1,6 @@
 package douyulive
 
-import (
-	"bytes"
-	"encoding/binary"
-	"strings"
-	"unsafe"
-)
+import "douyu-barrage/stt"
 
 const (
 	HeadLen    uint32 = 4
@@ -15,34 +10,14 @@ const (
 
 // 序列化消息
 func serializeMsg(msg map[string]string) string {
-	var buf bytes.Buffer
-	for k, v := range msg {
-		buf.WriteString(k + "@=" + v + "/")
-	}
-	buf.WriteByte(0)
-	return buf.String()
+	data, _ := stt.Marshal(msg)
+	return string(data) + "\x00"
 }
 
 // 反序列化消息
-func unserializeMsg(str *string) map[string]string {
+func unserializeMsg(data []byte) map[string]string {
 	m := make(map[string]string)
-	if (*str)[len(*str)-1:] != string(0) {
-		return m
-	}
-	// 截取最后的空字符和/
-	*str = (*str)[:len(*str)-2]
-	slis := strings.Split(*str, "/")
-	if len(slis) <= 1 {
-		return m
-	}
-	// 分隔符@=
-	for _, v := range slis {
-		s := strings.Split(v, "@=")
-		if len(s) <= 1 {
-			continue
-		}
-		m[s[0]] = s[1]
-	}
+	_ = stt.Unmarshal(data, &m)
 	return m
 }
 
@@ -53,26 +28,8 @@ func combinationProtocolHead(msg map[string]string) []byte {
 	}
 	// 序列化
 	s := serializeMsg(msg)
-	// fmt.Println(s, len(s))
-
-	// 计算头长度
-	head := make([]byte, HeadLen*2+MsgTypeLen+KeepLen)
-	// head := make([]byte, 0)
-
-	body := []byte(s)
-
-	// 计算总长度
 
-	totalLen := uint32(len(head) + len(body))
-	// fmt.Println(totalLen)
-	binary.LittleEndian.PutUint32(head, totalLen-HeadLen)
-	binary.LittleEndian.PutUint32(head[4:], totalLen-HeadLen)
-	binary.LittleEndian.PutUint16(head[8:], 689)
-	binary.LittleEndian.PutUint16(head[10:], 0)
-	// fmt.Println("head", len(head), head)
-	// fmt.Println("body", len(body), body)
-	// fmt.Println("sum", len(append(head, body...)), append(head, body...))
-	return append(head, body...)
+	return EncodeFrame(ClientMsgType, []byte(s))
 }
 
 func MsgToByte(msg map[string]string) []byte {
@@ -84,7 +41,6 @@ func MsgToByte(msg map[string]string) []byte {
 
 // 解析数据
 func ByteToMsg(data []byte) map[string]string {
-	str := (*string)(unsafe.Pointer(&data))
 	// 反序列化
-	return unserializeMsg(str)
+	return unserializeMsg(data)
 }
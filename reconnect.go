@@ -0,0 +1,171 @@
+package douyulive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ReconnectPolicy 重连策略，零值字段使用默认值
+type ReconnectPolicy struct {
+	InitialBackoff time.Duration // 首次重连前的等待时间，默认1秒
+	MaxBackoff     time.Duration // 最长等待时间，默认1分钟
+	Multiplier     float64       // 每次失败后等待时间的倍数，默认2
+	Jitter         float64       // 等待时间的随机抖动比例，取值0~1，默认0.2
+	MaxAttempts    int           // 连续失败多少次后放弃，0表示不限，小于0表示不重连
+}
+
+func (p ReconnectPolicy) withDefaults() ReconnectPolicy {
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = time.Second
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = time.Minute
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	if p.Jitter <= 0 || p.Jitter > 1 {
+		p.Jitter = 0.2
+	}
+	return p
+}
+
+// 第attempt次重连前的等待时间，attempt从1开始
+func (p ReconnectPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	// 在 [d*(1-Jitter), d*(1+Jitter)] 内随机
+	d += d * p.Jitter * (rand.Float64()*2 - 1)
+	return time.Duration(d)
+}
+
+// ConnectionEventType 连接事件类型
+type ConnectionEventType int
+
+const (
+	Connecting   ConnectionEventType = iota // 开始连接
+	Connected                               // 连接并发送登录请求成功
+	Disconnected                            // 连接断开
+	GaveUp                                  // 达到最大重连次数，放弃重连并移出房间
+)
+
+func (t ConnectionEventType) String() string {
+	switch t {
+	case Connecting:
+		return "Connecting"
+	case Connected:
+		return "Connected"
+	case Disconnected:
+		return "Disconnected"
+	case GaveUp:
+		return "GaveUp"
+	default:
+		return fmt.Sprintf("ConnectionEventType(%d)", int(t))
+	}
+}
+
+// ConnectionEvent 连接事件
+type ConnectionEvent struct {
+	Type    ConnectionEventType
+	Server  string // 弹幕服务器地址
+	Attempt int    // 第几次重连，首次连接为0
+	Err     error  // 连接失败、断开或放弃的原因
+}
+
+func (live *Live) emitConnectionEvent(roomID int, event *ConnectionEvent) {
+	if live.ConnectionEventHandler != nil {
+		live.ConnectionEventHandler(roomID, event)
+	}
+}
+
+// ErrGaveUp 超过最大重连次数或遇到无法恢复的错误，房间已被移出
+var ErrGaveUp = errors.New("放弃重连")
+
+// 连接并登录房间，发送对应的连接事件
+func (live *Live) connectRoom(ctx context.Context, room *liveRoom, attempt int) error {
+	if attempt == 0 {
+		room.status.set(StateConnecting)
+	} else {
+		room.status.addReconnect()
+		room.metrics.Reconnect(room.roomID)
+	}
+	live.emitConnectionEvent(room.roomID, &ConnectionEvent{Type: Connecting, Server: room.address(), Attempt: attempt})
+	if err := room.enter(ctx); err != nil {
+		room.status.setError(err)
+		live.emitConnectionEvent(room.roomID, &ConnectionEvent{Type: Disconnected, Server: room.address(), Attempt: attempt, Err: err})
+		return err
+	}
+	room.status.set(StateConnected)
+	live.emitConnectionEvent(room.roomID, &ConnectionEvent{Type: Connected, Server: room.address(), Attempt: attempt})
+	return nil
+}
+
+// 守护房间连接：连接断开后按重连策略重新连接并登录，直到ctx结束或放弃重连
+func (live *Live) supervise(ctx context.Context, room *liveRoom) {
+	policy := live.Reconnect.withDefaults()
+
+	for {
+		err := live.runSession(ctx, room)
+		if ctx.Err() != nil || room.firstLoginFailed() {
+			return
+		}
+		room.status.setError(err)
+		room.status.set(StateReconnecting)
+		live.emitConnectionEvent(room.roomID, &ConnectionEvent{Type: Disconnected, Server: room.address(), Err: err})
+		live.reportError(room.roomID, err)
+
+		for attempt := 1; ; attempt++ {
+			// 房间ID错误、签名错误等重连也无法恢复，直接放弃
+			if policy.MaxAttempts < 0 || (policy.MaxAttempts > 0 && attempt > policy.MaxAttempts) || isPermanent(err) {
+				room.log.error("放弃重连", "attempt", attempt-1, "err", err)
+				live.rooms.drop(room)
+				room.cancel()
+				room.status.set(StateFailed)
+				gaveUp := fmt.Errorf("%w: %v", ErrGaveUp, err)
+				live.emitConnectionEvent(room.roomID, &ConnectionEvent{Type: GaveUp, Server: room.address(), Attempt: attempt - 1, Err: gaveUp})
+				live.reportError(room.roomID, gaveUp)
+				return
+			}
+			if !sleepContext(ctx, policy.backoff(attempt)) {
+				return
+			}
+			room.log.info("尝试重新连接", "server", room.address(), "attempt", attempt)
+			if err = live.connectRoom(ctx, room, attempt); err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			live.reportError(room.roomID, err)
+		}
+	}
+}
+
+// 在当前连接上运行心跳与接收，任一失败后关闭连接并返回原因
+func (live *Live) runSession(ctx context.Context, room *liveRoom) error {
+	conn := room.currentConn()
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, 2)
+	go func() {
+		errs <- room.heartBeat(sessionCtx, conn)
+	}()
+	go func() {
+		errs <- room.receive(sessionCtx, conn, live.enqueue)
+	}()
+
+	err := <-errs
+	cancel()
+	_ = conn.Close()
+	<-errs
+	// 收到登录结果前连接已断开
+	room.finishLogin(err)
+	return err
+}
@@ -0,0 +1,187 @@
+package douyulive
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReconnectPolicy_Backoff(t *testing.T) {
+	policy := ReconnectPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+		Jitter:         0.5,
+	}.withDefaults()
+
+	tests := []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{100, time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			got := policy.backoff(tt.attempt)
+			if got < tt.base/2 || got > tt.base*3/2 {
+				t.Fatalf("backoff(%d) = %v, want within %v±50%%", tt.attempt, got, tt.base)
+			}
+		}
+	}
+}
+
+// 本地弹幕服务器，每个连接收到loginreq后响应登录并交给onLogin处理
+func listenLogin(t *testing.T, onLogin func(conn net.Conn, count int)) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	var (
+		mu    sync.Mutex
+		count int
+	)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := NewFrameReader(conn)
+				for {
+					_, body, err := reader.ReadFrame()
+					if err != nil {
+						return
+					}
+					if ByteToMsg(body)["type"] == "loginreq" {
+						_, _ = conn.Write(loginRespFrame())
+						mu.Lock()
+						count++
+						n := count
+						mu.Unlock()
+						onLogin(conn, n)
+					}
+				}
+			}()
+		}
+	}()
+	return ln
+}
+
+// 记录连接事件
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []ConnectionEvent
+	ch     chan ConnectionEvent
+}
+
+func newEventRecorder() *eventRecorder {
+	return &eventRecorder{ch: make(chan ConnectionEvent, 100)}
+}
+
+func (r *eventRecorder) handle(roomID int, event *ConnectionEvent) {
+	r.mu.Lock()
+	r.events = append(r.events, *event)
+	r.mu.Unlock()
+	r.ch <- *event
+}
+
+// 等待指定类型的事件
+func (r *eventRecorder) wait(t *testing.T, eventType ConnectionEventType) ConnectionEvent {
+	t.Helper()
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case event := <-r.ch:
+			if event.Type == eventType {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("等待 %s 事件超时", eventType)
+		}
+	}
+}
+
+func TestLive_Reconnect(t *testing.T) {
+	// 第一次登录后立即断开连接
+	ln := listenLogin(t, func(conn net.Conn, count int) {
+		if count == 1 {
+			_ = conn.Close()
+		}
+	})
+	addr := ln.Addr()
+
+	recorder := newEventRecorder()
+	live := &Live{
+		TokenProvider:          testToken,
+		ConnectionEventHandler: recorder.handle,
+		Reconnect:              ReconnectPolicy{InitialBackoff: 10 * time.Millisecond},
+	}
+	live.Start(context.Background())
+	defer live.Close(context.Background())
+
+	if err := live.Join("aid", "secret", testServers(addr), 1); err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+
+	recorder.wait(t, Connected)
+	if event := recorder.wait(t, Disconnected); event.Err == nil {
+		t.Fatal("Disconnected 事件没有原因")
+	}
+	if event := recorder.wait(t, Connected); event.Attempt != 1 {
+		t.Fatalf("重连 Attempt = %d, want 1", event.Attempt)
+	}
+}
+
+func TestLive_ReconnectGaveUp(t *testing.T) {
+	var ln net.Listener
+	// 登录后关闭监听与连接，之后的重连都会失败
+	ln = listenLogin(t, func(conn net.Conn, count int) {
+		_ = ln.Close()
+		_ = conn.Close()
+	})
+	addr := ln.Addr()
+
+	recorder := newEventRecorder()
+	live := &Live{
+		TokenProvider:          testToken,
+		ConnectionEventHandler: recorder.handle,
+		Reconnect:              ReconnectPolicy{InitialBackoff: 10 * time.Millisecond, MaxAttempts: 2},
+	}
+	live.Start(context.Background())
+	defer live.Close(context.Background())
+
+	if err := live.Join("aid", "secret", testServers(addr), 1); err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+
+	event := recorder.wait(t, GaveUp)
+	if event.Attempt != 2 || event.Err == nil {
+		t.Fatalf("GaveUp 事件 = %+v", event)
+	}
+	if rooms := live.Rooms(); len(rooms) != 0 {
+		t.Fatalf("放弃重连后 Rooms() = %v, want empty", rooms)
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	var connecting int
+	for _, e := range recorder.events {
+		if e.Type == Connecting {
+			connecting++
+		}
+	}
+	if connecting != 3 {
+		t.Fatalf("Connecting 事件 %d 次, want 3", connecting)
+	}
+}
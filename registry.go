@@ -0,0 +1,142 @@
+package douyulive
+
+import (
+	"sort"
+	"sync"
+)
+
+// 直播间注册表，Join、Remove、重连与消息分析协程会并发访问
+type roomRegistry struct {
+	mu      sync.RWMutex
+	rooms   map[int]*liveRoom
+	removed map[int]*liveRoom // 已移出或失败的房间，保留最后的状态供Status查询，重新加入或Forget后清除
+	closed  bool
+}
+
+func newRoomRegistry() *roomRegistry {
+	return &roomRegistry{rooms: make(map[int]*liveRoom), removed: make(map[int]*liveRoom)}
+}
+
+func (r *roomRegistry) get(roomID int) (*liveRoom, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	room, exist := r.rooms[roomID]
+	return room, exist
+}
+
+func (r *roomRegistry) exist(roomID int) bool {
+	_, exist := r.get(roomID)
+	return exist
+}
+
+// 注册房间，并在持有锁时调用start启动房间协程，保证closeAll不会遗漏刚加入的房间
+func (r *roomRegistry) add(room *liveRoom, start func()) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrClosed
+	}
+	if _, exist := r.rooms[room.roomID]; exist {
+		return ErrRoomExists
+	}
+	r.rooms[room.roomID] = room
+	delete(r.removed, room.roomID)
+	start()
+	return nil
+}
+
+func (r *roomRegistry) remove(roomID int) (*liveRoom, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	room, exist := r.rooms[roomID]
+	if exist {
+		delete(r.rooms, roomID)
+		r.removed[roomID] = room
+	}
+	return room, exist
+}
+
+// 移出指定的房间，房间ID已被其他房间使用时不做处理
+func (r *roomRegistry) drop(room *liveRoom) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rooms[room.roomID] == room {
+		delete(r.rooms, room.roomID)
+		r.removed[room.roomID] = room
+	}
+}
+
+// 记录没有加入注册表就失败的房间，房间ID正在使用时不做处理
+func (r *roomRegistry) retire(room *liveRoom) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exist := r.rooms[room.roomID]; !exist {
+		r.removed[room.roomID] = room
+	}
+}
+
+// 查找房间，房间已移出时返回保留的房间
+func (r *roomRegistry) lookup(roomID int) (*liveRoom, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if room, exist := r.rooms[roomID]; exist {
+		return room, true
+	}
+	room, exist := r.removed[roomID]
+	return room, exist
+}
+
+// 清除已移出房间保留的状态
+func (r *roomRegistry) forget(roomID int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.removed, roomID)
+}
+
+// 移出所有房间，之后不再接受新的房间
+func (r *roomRegistry) closeAll() []*liveRoom {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	rooms := make([]*liveRoom, 0, len(r.rooms))
+	for roomID, room := range r.rooms {
+		rooms = append(rooms, room)
+		delete(r.rooms, roomID)
+		r.removed[roomID] = room
+	}
+	return rooms
+}
+
+// 房间ID快照，按升序排列
+func (r *roomRegistry) ids() []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]int, 0, len(r.rooms))
+	for roomID := range r.rooms {
+		ids = append(ids, roomID)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// 当前与已移出房间的ID快照，按升序排列
+func (r *roomRegistry) allIDs() []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]int, 0, len(r.rooms)+len(r.removed))
+	for roomID := range r.rooms {
+		ids = append(ids, roomID)
+	}
+	for roomID := range r.removed {
+		if _, exist := r.rooms[roomID]; !exist {
+			ids = append(ids, roomID)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// Rooms 返回当前已添加的房间ID
+func (live *Live) Rooms() []int {
+	return live.rooms.ids()
+}
@@ -0,0 +1,131 @@
+package douyulive
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 本地弹幕服务器，响应登录请求，丢弃收到的其他数据
+func listenDiscard(t *testing.T) net.Addr {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := NewFrameReader(conn)
+				for {
+					_, body, err := reader.ReadFrame()
+					if err != nil {
+						return
+					}
+					if ByteToMsg(body)["type"] == "loginreq" {
+						_, _ = conn.Write(loginRespFrame())
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr()
+}
+
+// 登录成功的响应
+func loginRespFrame() []byte {
+	return EncodeFrame(ServerMsgType, []byte(serializeMsg(map[string]string{"type": LoginRespType})))
+}
+
+func testServers(addr net.Addr) []*HostServer {
+	tcpAddr := addr.(*net.TCPAddr)
+	return []*HostServer{{Host: tcpAddr.IP.String(), Port: tcpAddr.Port}}
+}
+
+// 测试中不访问开放平台
+var testToken = StaticTokenProvider("token")
+
+func TestLive_Rooms(t *testing.T) {
+	addr := listenDiscard(t)
+
+	live := &Live{TokenProvider: testToken}
+	live.Start(context.Background())
+	defer live.Close(context.Background())
+
+	if err := live.Join("aid", "secret", testServers(addr), 3, 1, 2); err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+	if err := live.Join("aid", "secret", testServers(addr), 2); err == nil {
+		t.Fatal("Join() 重复房间 error = nil")
+	}
+	if got, want := live.Rooms(), []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Rooms() = %v, want %v", got, want)
+	}
+	if err := live.Remove(2); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if got, want := live.Rooms(), []int{1, 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Rooms() = %v, want %v", got, want)
+	}
+}
+
+func TestLive_JoinRemoveStress(t *testing.T) {
+	addr := listenDiscard(t)
+	before := runtime.NumGoroutine()
+
+	live := &Live{AnalysisRoutineNum: 4, TokenProvider: testToken}
+	live.Start(context.Background())
+
+	// 多个Live实例互不影响
+	other := &Live{TokenProvider: testToken}
+	other.Start(context.Background())
+	if err := other.Join("aid", "secret", testServers(addr), 1); err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for j := 0; j < 30; j++ {
+				roomID := r.Intn(5) + 1
+				if r.Intn(2) == 0 {
+					_ = live.Join("aid", "secret", testServers(addr), roomID)
+				} else {
+					_ = live.Remove(roomID)
+				}
+				_ = live.Rooms()
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+
+	if got := other.Rooms(); !reflect.DeepEqual(got, []int{1}) {
+		t.Fatalf("other.Rooms() = %v, want [1]", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := live.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := other.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	live.Wait()
+	other.Wait()
+	checkGoroutineLeak(t, before)
+}
@@ -1,23 +1,20 @@
 package douyulive
 
 import (
-	"bytes"
-	"compress/zlib"
 	"context"
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"io"
-	"log"
 	"math/rand"
 	"net"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	LoginRespType             = "loginresp"
+	LoginRespType             = "loginres"
+	ErrorRespType             = "error"
 	BarrageRespType           = "chatmsg"
 	StormRespType             = "onlinegift"
 	SendGiftRespType          = "dgb"
@@ -28,96 +25,193 @@ const (
 	RoomGiftBroadcastRespType = "spbc"
 )
 
+// DefaultLoginTimeout Join等待登录响应的默认时间
+const DefaultLoginTimeout = 10 * time.Second
+
+// ErrClosed 调用Close后再次操作
+var ErrClosed = errors.New("已关闭")
+
+// ErrHandlerPanic handler发生panic，通过ErrorHandler通知
+var ErrHandlerPanic = errors.New("handler panic")
+
+// ErrRoomExists 加入已存在的房间
+var ErrRoomExists = errors.New("房间已存在")
+
 // Start 开始接收
 func (live *Live) Start(ctx context.Context) {
-	live.ctx = ctx
+	live.ctx, live.cancel = context.WithCancel(ctx)
 
 	rand.Seed(time.Now().Unix())
 	if live.AnalysisRoutineNum <= 0 {
 		live.AnalysisRoutineNum = 1
 	}
 
-	live.room = make(map[int]*liveRoom)
-	live.chSocketMessage = make(chan *socketMessage, 30)
-	chReconSignal = make(chan *liveRoom, 1)
+	live.log = newSDKLogger(live.Logger, live.Debug)
+	live.metrics = metricsOrNop(live.Metrics)
+	live.lowPriority = live.lowPriorityTypes()
+	live.registerBuiltinHandlers()
+	live.rooms = newRoomRegistry()
+	live.serverHealth = newServerHealth(live.ServerCooldown)
+	if live.TokenProvider == nil {
+		live.TokenProvider = &CachedTokenProvider{BaseURL: live.OpenAPIBaseURL, HTTPClient: live.HTTPClient, Metrics: live.Metrics}
+	}
 
 	live.wg = sync.WaitGroup{}
 
-	live.wg.Add(1)
-	go func() {
-		defer live.wg.Done()
-		live.split(ctx)
-	}()
+	live.workers = make([]*analysisWorker, live.AnalysisRoutineNum)
+	for i := range live.workers {
+		worker := newAnalysisWorker(i, live.messageBuffer())
+		live.workers[i] = worker
+
+		live.wg.Add(1)
+		go func() {
+			defer live.wg.Done()
+			live.split(live.ctx, worker)
+		}()
+	}
 }
 
+// Wait 等待所有协程退出，Start传入的ctx结束或调用Close后返回
 func (live *Live) Wait() {
 	live.wg.Wait()
+	live.roomWg.Wait()
 }
 
-// Join 添加房间
-func (live *Live) Join(aid, secret, ip string, port int, roomIDs ...int) error {
+// Join 添加房间，servers为可用的弹幕服务器，连接失败时依次切换，为空时使用斗鱼默认的弹幕服务器
+// 房间使用Live.Transport连接，未设置时使用TCP
+// 等待LoginTimeout内收到登录响应，服务器返回error消息或超时的房间会被移出
+// 部分房间失败时其余房间仍会加入，返回*JoinError说明每个失败房间的原因，可用errors.Is判断ErrInvalidRoomID、ErrLoginTimeout等
+func (live *Live) Join(aid, secret string, servers []*HostServer, roomIDs ...int) error {
+	return live.JoinWithTransport(live.Transport, aid, secret, servers, roomIDs...)
+}
+
+// JoinWithTransport 使用指定的传输方式添加房间，transport为nil时使用TCP
+func (live *Live) JoinWithTransport(transport Transport, aid, secret string, servers []*HostServer, roomIDs ...int) error {
 	if len(roomIDs) == 0 {
 		return errors.New("没有要添加的房间")
 	}
+	if live.isClosed() {
+		return ErrClosed
+	}
 
+	errs := make(map[int]error)
+	logins := make(map[int]<-chan error, len(roomIDs))
+	var joined []int
 	for _, roomID := range roomIDs {
-		if _, exist := live.room[roomID]; exist {
-			return fmt.Errorf("房间 %d 已存在", roomID)
+		if _, ok := logins[roomID]; ok {
+			continue
+		}
+		if _, ok := errs[roomID]; ok {
+			continue
+		}
+		if live.rooms.exist(roomID) {
+			errs[roomID] = ErrRoomExists
+			continue
 		}
-	}
-	for _, roomID := range roomIDs {
 		nextCtx, cancel := context.WithCancel(live.ctx)
 
 		room := &liveRoom{
-			roomID: roomID,
-			cancel: cancel,
-			aid:    aid,
-			secret: secret,
-			server: ip,
-			port:   port,
+			roomID:            roomID,
+			cancel:            cancel,
+			aid:               aid,
+			secret:            secret,
+			hostServerList:    normalizeServers(servers),
+			health:            live.serverHealth,
+			transport:         transport,
+			dialer:            live.Dialer,
+			tokens:            live.TokenProvider,
+			heartbeatInterval: live.heartbeatInterval(),
+			heartbeatTimeout:  live.heartbeatTimeout(),
+			log:               live.log.with("room", roomID),
+			metrics:           live.metrics,
+			joining:           live.LoginTimeout >= 0,
 		}
-		live.room[roomID] = room
-		room.enter()
-		go room.heartBeat(nextCtx)
-		go room.receive(nextCtx, live.chSocketMessage)
+		room.status.onChange = func(from, to RoomState) { live.emitStateChange(room.roomID, from, to) }
+		if room.transport == nil {
+			room.transport = TCPTransport{}
+		}
+		if err := live.connectRoom(nextCtx, room, 0); err != nil {
+			cancel()
+			room.status.set(StateFailed)
+			live.rooms.retire(room)
+			errs[roomID] = err
+			continue
+		}
+		if err := live.rooms.add(room, func() { live.runRoom(nextCtx, room) }); err != nil {
+			// 连接期间房间被并发添加或已关闭
+			cancel()
+			room.closeConn()
+			if errors.Is(err, ErrClosed) {
+				return ErrClosed
+			}
+			errs[roomID] = err
+			continue
+		}
+		logins[roomID] = room.loginResult()
+		joined = append(joined, roomID)
+	}
+
+	if err := live.waitLogin(joined, logins, errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return &JoinError{Errors: errs}
 	}
 	return nil
 }
 
-// 房间异常断开后的重连
-func (live *Live) ReJoin(ctx context.Context) {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Println("尝试重新连接失败：", err)
-			return
-		}
-	}()
+// 等待房间的登录结果，登录失败或超时的房间会被移出，原因记录到errs
+func (live *Live) waitLogin(roomIDs []int, logins map[int]<-chan error, errs map[int]error) error {
+	timeout := live.LoginTimeout
+	if timeout < 0 {
+		return nil
+	}
+	if timeout == 0 {
+		timeout = DefaultLoginTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case liveRoomInfo := <-chReconSignal:
-			if liveRoomInfo.reconnect {
-				nextCtx, cancel := context.WithCancel(live.ctx)
-
-				room := &liveRoom{
-					roomID: liveRoomInfo.roomID,
-					cancel: cancel,
-					aid:    liveRoomInfo.aid,
-					secret: liveRoomInfo.secret,
-					server: liveRoomInfo.server,
-					port:   liveRoomInfo.port,
-				}
-				live.room[liveRoomInfo.roomID] = room
-				room.enter()
-				go room.heartBeat(nextCtx)
-				go room.receive(nextCtx, live.chSocketMessage)
+	var expired bool
+	for _, roomID := range roomIDs {
+		var err error
+		if expired {
+			// 已超时，只检查剩余房间是否已有结果
+			select {
+			case err = <-logins[roomID]:
+			default:
+				err = ErrLoginTimeout
 			}
-
-		default:
+		} else {
+			select {
+			case err = <-logins[roomID]:
+			case <-timer.C:
+				expired = true
+				err = ErrLoginTimeout
+			case <-live.ctx.Done():
+				return ErrClosed
+			}
+		}
+		if err == nil {
+			continue
 		}
+		if room, exist := live.rooms.get(roomID); exist {
+			room.status.setError(err)
+			room.status.set(StateFailed)
+		}
+		_ = live.Remove(roomID)
+		errs[roomID] = fmt.Errorf("登录失败: %w", err)
+	}
+	return nil
+}
 
+// ReJoin 阻塞直到ctx结束或Live关闭
+//
+// Deprecated: 房间断开后会按Reconnect策略自动重连，无需再调用
+func (live *Live) ReJoin(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-live.ctx.Done():
 	}
 }
 
@@ -128,260 +222,337 @@ func (live *Live) Remove(roomIDs ...int) error {
 	}
 
 	for _, roomID := range roomIDs {
-		if room, exist := live.room[roomID]; exist {
+		if room, exist := live.rooms.remove(roomID); exist {
 			room.cancel()
-			delete(live.room, roomID)
+			room.status.set(StateClosed)
 		}
 	}
 	return nil
 }
 
+// Close 登出并关闭所有房间，按ClosePolicy处理队列中未分析的消息，等待所有协程退出后返回
+// ctx结束时不再等待，返回ctx.Err()
+func (live *Live) Close(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&live.closed, 0, 1) {
+		return ErrClosed
+	}
+	// 等待超时也要结束剩余协程
+	defer live.cancel()
+
+	for _, room := range live.rooms.closeAll() {
+		room.logout()
+		room.cancel()
+		room.closeConn()
+		room.status.set(StateClosed)
+	}
+	if err := waitContext(ctx, &live.roomWg); err != nil {
+		return err
+	}
+
+	// 房间协程都已退出，不会再有新消息写入队列
+	for _, worker := range live.workers {
+		close(worker.chSocketMessage)
+	}
+	return waitContext(ctx, &live.wg)
+}
+
+func (live *Live) isClosed() bool {
+	return atomic.LoadInt32(&live.closed) == 1
+}
+
+// 等待wg完成或ctx结束
+func waitContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 启动房间的守护协程
+func (live *Live) runRoom(ctx context.Context, room *liveRoom) {
+	live.roomWg.Add(1)
+	go func() {
+		defer live.roomWg.Done()
+		live.supervise(ctx, room)
+	}()
+}
+
 // 拆分数据
-func (live *Live) split(ctx context.Context) {
-	var (
-		message *socketMessage
-	)
+func (live *Live) split(ctx context.Context, worker *analysisWorker) {
 	for {
-		message = <-live.chSocketMessage
-		for len(message.body) > 0 {
-			select {
-			case <-ctx.Done():
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-worker.chSocketMessage:
+			if !ok {
 				return
-			default:
 			}
-
-			switch message.body["type"] {
-			case LoginRespType:
-				live.room[message.roomID].joinGroup()
-				if live.LoginRespMessageHandler != nil {
-					live.LoginRespMessageHandler(message.roomID, TransferLoginRespMessage(message.body))
-				}
-			case BarrageRespType:
-				if live.BarrageMessageHandler != nil {
-					live.BarrageMessageHandler(message.roomID, TransferBarrageMessage(message.body))
-				}
-			case StormRespType:
-				if live.StormMessageHandler != nil {
-					live.StormMessageHandler(message.roomID, TransferStormMessage(message.body))
-				}
-			case SendGiftRespType:
-				if live.SendGiftMessageHandler != nil {
-					live.SendGiftMessageHandler(message.roomID, TransferSendGiftMessage(message.body))
-				}
-			case SpecialUserRespType:
-				if live.SpecialUserMessageHandler != nil {
-					live.SpecialUserMessageHandler(message.roomID, TransferSpecialUserMessage(message.body))
-				}
-			case SwitchBroadcastRespType:
-				if live.SwitchBroadcastMessageHandler != nil {
-					live.SwitchBroadcastMessageHandler(message.roomID, TransferSwitchBroadcastMessage(message.body))
-				}
-			case BroadcastRankRespType:
-				if live.BroadcastRankMessageHandler != nil {
-					live.BroadcastRankMessageHandler(message.roomID, TransferBroadcastRankMessage(message.body))
-				}
-			case SuperBarrageRespType:
-				if live.SuperBarrageMessageHandler != nil {
-					live.SuperBarrageMessageHandler(message.roomID, TransferSuperBarrageMessage(message.body))
-				}
-			case RoomGiftBroadcastRespType:
-				if live.RoomGiftBarrageMessageHandler != nil {
-					live.RoomGiftBarrageMessageHandler(message.roomID, TransferRoomGiftBroadcastMessage(message.body))
-				}
-
-			default:
-
+			live.metrics.QueueDepth(worker.index, len(worker.chSocketMessage))
+			if live.isClosed() && live.ClosePolicy == CloseDrop {
+				live.metrics.MessageDropped(message.roomID, message.body["type"])
+				continue
 			}
-
-			break
+			start := time.Now()
+			live.dispatch(message)
+			live.metrics.HandlerLatency(message.body["type"], time.Since(start))
+			atomic.AddUint64(&worker.processed, 1)
 		}
-
 	}
 }
 
-func (room *liveRoom) createConnect() {
-	for {
-		if room.server == "" || room.port == 0 {
-			room.server = "openapi-danmu.douyu.com"
-			room.port = 80
+// 按消息类型通知对应的handler
+func (live *Live) dispatch(message *socketMessage) {
+	defer func() {
+		// handler的panic不能结束消息分析协程
+		if r := recover(); r != nil {
+			live.reportError(message.roomID, fmt.Errorf("%w: %v", ErrHandlerPanic, r))
 		}
+	}()
 
-		counter := 0
-		for {
-			log.Println("尝试创建连接：", room.server, room.port)
-			conn, err := connect(room.server, room.port)
-			if err != nil {
-				log.Println("connect err:", err)
-				if counter == 3 {
-					log.Printf("尝试创建连接失败: %s", err.Error())
-					break
-				}
-				time.Sleep(1 * time.Second)
-				counter++
-				continue
-			}
-			room.conn = conn
-			log.Println("连接创建成功：", room.server, room.port)
-			return
+	msgType := message.body["type"]
+	if live.RawMessageHandler != nil {
+		live.RawMessageHandler(message.roomID, msgType, message.body)
+	}
+
+	if msgType == HeartbeatType && live.HeartbeatRTTHandler != nil {
+		if room, exist := live.rooms.get(message.roomID); exist && room.lastRTT() > 0 {
+			live.HeartbeatRTTHandler(message.roomID, room.lastRTT())
 		}
 	}
+	decode := func(msg interface{}) bool { return live.decode(message.roomID, msgType, message.body, msg) }
+	if !live.handlers.dispatch(message.roomID, msgType, message.body, decode) && live.UnknownMessageHandler != nil {
+		live.UnknownMessageHandler(message.roomID, msgType, message.body)
+	}
 }
 
-func (room *liveRoom) enter() {
-	room.createConnect()
+// 通知异步发生的错误
+func (live *Live) reportError(roomID int, err error) {
+	if live.ErrorHandler != nil {
+		live.ErrorHandler(roomID, err)
+	}
+}
 
-	currentTime := time.Now()
-	if room.token == "" || currentTime.Unix()-room.tokenTime >= 60*60*2 {
-		token, err := GenerateToken(room.aid, room.secret, currentTime)
+// 按候选顺序连接弹幕服务器，失败的服务器进入冷却期
+func (room *liveRoom) createConnect(ctx context.Context) (net.Conn, error) {
+	var lastErr error
+	for _, index := range room.candidateServers() {
+		server := room.hostServerList[index]
+
+		room.log.debug("尝试创建连接", "server", server.Address())
+		conn, err := room.transport.Dial(ctx, room.dialer, server)
 		if err != nil {
-			log.Panic(err)
+			room.log.warn("创建连接失败", "server", server.Address(), "err", err)
+			room.health.markDown(server)
+			lastErr = err
+			continue
 		}
-		room.token = token
-		room.tokenTime = currentTime.Unix()
-	}
-
-	room.login(currentTime)
+		room.log.info("连接创建成功", "server", server.Address())
+		room.health.markUp(server)
 
+		room.mu.Lock()
+		room.currentServerIndex = index
+		room.mu.Unlock()
+		return conn, nil
+	}
+	return nil, lastErr
 }
 
-// 登录
-func (room *liveRoom) login(currentTime time.Time) {
-	auth := Md5(fmt.Sprintf("%s_%s_%d_%s", room.secret, room.aid, currentTime.Unix(), room.token))
+// 登录与入组请求的写超时
+const requestWriteTimeout = 10 * time.Second
 
-	loginMessage := MsgToByte(map[string]string{"type": "loginreq", "roomid": strconv.Itoa(room.roomID), "aid": room.aid, "token": room.token, "time": strconv.FormatInt(currentTime.Unix(), 10), "auth": auth})
+// 获取token，创建连接并登录
+func (room *liveRoom) enter(ctx context.Context) error {
+	token, err := room.tokens.Token(ctx, room.aid, room.secret)
+	if err != nil {
+		return fmt.Errorf("获取token失败: %w", err)
+	}
 
-	fmt.Println(string(loginMessage))
-	// 登录弹幕服务器
-	if _, err := room.conn.Write(loginMessage); err != nil {
-		log.Panic("login failed:", err)
+	conn, err := room.createConnect(ctx)
+	if err != nil {
+		return err
 	}
 
+	currentTime := time.Now()
+	auth, err := room.login(conn, token, currentTime)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	room.token = token
 	room.auth = auth
+	room.loginTime = currentTime.Unix()
+	room.loginWait = make(chan error, 1)
+	room.conn = conn
+	return nil
 }
 
-// 入组
-func (room *liveRoom) joinGroup() {
+// 登录，返回入组时使用的auth，写入时不持有room.mu
+func (room *liveRoom) login(conn net.Conn, token string, currentTime time.Time) (string, error) {
+	auth := Md5(fmt.Sprintf("%s_%s_%d_%s", room.secret, room.aid, currentTime.Unix(), token))
+
+	loginMessage := MsgToByte(map[string]string{"type": "loginreq", "roomid": strconv.Itoa(room.roomID), "aid": room.aid, "token": token, "time": strconv.FormatInt(currentTime.Unix(), 10), "auth": auth})
+
+	room.log.debug("发送登录请求", "aid", room.aid, "token", token, "auth", auth)
+	// 登录弹幕服务器
+	if err := writeWithTimeout(conn, loginMessage); err != nil {
+		return "", fmt.Errorf("login failed: %w", err)
+	}
+	return auth, nil
+}
 
+// 入组，使用登录时的token、时间戳与auth
+func (room *liveRoom) joinGroup(conn net.Conn) error {
+	room.mu.Lock()
 	joinGroupMessage := MsgToByte(map[string]string{
 		"type":  "joingroup",
 		"rid":   strconv.Itoa(room.roomID),
 		"token": room.token,
-		"time":  strconv.FormatInt(room.tokenTime, 10),
+		"time":  strconv.FormatInt(room.loginTime, 10),
 		"auth":  room.auth,
 	})
+	room.mu.Unlock()
 
-	fmt.Println(string(joinGroupMessage))
+	room.log.debug("发送入组请求")
 
-	// 加入组
-	if _, err := room.conn.Write(joinGroupMessage); err != nil {
-		log.Panic("joinGroup failed:", err)
+	// 加入组，写入失败时连接已断开，由重连处理
+	if err := writeWithTimeout(conn, joinGroupMessage); err != nil {
+		return fmt.Errorf("joinGroup failed: %w", err)
 	}
+	return nil
+}
 
+// 带写超时发送请求，避免服务器不读取时一直阻塞
+func writeWithTimeout(conn net.Conn, b []byte) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(requestWriteTimeout))
+	_, err := conn.Write(b)
+	_ = conn.SetWriteDeadline(time.Time{})
+	return err
 }
 
-// 心跳
-func (room *liveRoom) heartBeat(ctx context.Context) {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Printf("heatbeat failed: %s", err)
-		}
-	}()
-	time.Sleep(3 * time.Second)
-	var errorCount = 0
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
+// 登出，尽力而为，不处理失败
+func (room *liveRoom) logout() {
+	conn := room.currentConn()
+	_ = conn.SetWriteDeadline(time.Now().Add(time.Second))
+	_, _ = conn.Write(MsgToByte(map[string]string{"type": "logout"}))
+}
 
-		if _, err := room.conn.Write(MsgToByte(map[string]string{
-			"type": "mrkl",
-		})); err != nil {
-			if errorCount > 3 {
-				log.Println("尝试重新连接：", room.server, room.port)
-				_, cancel := context.WithCancel(ctx)
-				chReconSignal <- &liveRoom{
-					roomID:    room.roomID,
-					cancel:    cancel,
-					server:    room.server,
-					port:      room.port,
-					token:     room.token,
-					tokenTime: room.tokenTime,
-					aid:       room.aid,
-					secret:    room.secret,
-					auth:      room.auth,
-					reconnect: true,
-				}
-				break
-			}
-			log.Printf("heatbeat failed: %s", err.Error())
-			errorCount++
-			time.Sleep(3 * time.Second)
-			continue
-		}
-		errorCount = 0
-	}
+func (room *liveRoom) currentConn() net.Conn {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	return room.conn
+}
+
+func (room *liveRoom) closeConn() {
+	_ = room.currentConn().Close()
 }
 
-// 接收消息
-func (room *liveRoom) receive(ctx context.Context, chSocketMessage chan<- *socketMessage) {
-	// 包头总长12个字节
-	headerBuffer := make([]byte, HeadLen*2+MsgTypeLen+KeepLen)
+// 接收消息并交给enqueue放入分析队列，读取失败时返回
+func (room *liveRoom) receive(ctx context.Context, conn net.Conn, enqueue func(context.Context, *socketMessage) error) error {
+	reader := NewFrameReader(conn)
 
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return ctx.Err()
 		default:
 		}
 
-		// 读取协议头
-		n, err := room.conn.Read(headerBuffer)
+		if room.heartbeatTimeout > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(room.heartbeatTimeout))
+		}
+		_, body, err := reader.ReadFrame()
 		if err != nil {
-			if err == io.EOF {
-				continue
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				err = fmt.Errorf("%w: %v", ErrHeartbeatTimeout, err)
+				room.metrics.HeartbeatFailure(room.roomID)
+			} else if isFrameError(err) {
+				room.metrics.ParseError(room.roomID, err)
 			}
-			log.Println("read err:", err)
-			continue
+			// 读取失败后字节流已无法对齐，只能重新连接
+			room.log.warn("读取消息失败", "err", err)
+			return err
 		}
-
-		// 包体
-		var messageBody = make([]byte, int(binary.LittleEndian.Uint32(headerBuffer[0:4]))-int(HeadLen+MsgTypeLen+KeepLen))
-		n, err = room.conn.Read(messageBody)
-		if err != nil {
-			log.Println("read err:", err)
-			continue
+		now := time.Now()
+		atomic.StoreInt64(&room.lastFrame, now.UnixNano())
+		data := ByteToMsg(body)
+		room.status.countMessage(data["type"])
+		room.metrics.FrameReceived(room.roomID, data["type"], len(body))
+
+		var serverErr error
+		switch data["type"] {
+		case HeartbeatType:
+			room.pong(now)
+		case LoginRespType:
+			room.status.set(StateLoggedIn)
+			room.finishLogin(nil)
+			// 在接收协程中入组，不受分析队列积压与丢弃的影响
+			if err := room.joinGroup(conn); err != nil {
+				room.log.warn("入组失败", "err", err)
+				return err
+			}
+			room.status.set(StateJoined)
+		case ErrorRespType:
+			if code := StrToInt64(data["code"]); code != ServerCodeOK {
+				serverErr = &ServerError{RoomID: room.roomID, Code: code}
+				room.status.setError(serverErr)
+				room.finishLogin(serverErr)
+			}
 		}
-		data := ByteToMsg(messageBody[:n])
 
-		chSocketMessage <- &socketMessage{
-			roomID: room.roomID,
-			body:   data,
+		if err := enqueue(ctx, &socketMessage{roomID: room.roomID, body: data}); err != nil {
+			return err
+		}
+		if serverErr != nil {
+			// 服务器返回错误后连接不再可用
+			return serverErr
 		}
-
 	}
 }
 
-func connect(host string, port int) (*net.TCPConn, error) {
-	tcpAddr, err := net.ResolveTCPAddr("tcp4", fmt.Sprintf("%s:%d", host, port))
-	if err != nil {
-		return nil, err
-	}
-	return net.DialTCP("tcp", nil, tcpAddr)
+// 最近一次登录的结果
+func (room *liveRoom) loginResult() <-chan error {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	return room.loginWait
 }
 
-// 进行zlib解压缩
-func doZlibUnCompress(compressSrc []byte) []byte {
-	b := bytes.NewReader(compressSrc)
-	var out bytes.Buffer
-	r, err := zlib.NewReader(b)
-	if err != nil {
-		log.Println("zlib", err)
+// 通知登录结果，只有第一次通知生效
+func (room *liveRoom) finishLogin(err error) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	if room.loginWait != nil {
+		room.loginWait <- err
+		room.loginWait = nil
+		room.loggedIn = room.loggedIn || err == nil
 	}
-	_, err = io.Copy(&out, r)
-	if err != nil {
-		log.Println("zlib copy", err)
+}
+
+// 首次登录失败且Join正在等待结果，由Join移出房间并通过JoinError通知
+func (room *liveRoom) firstLoginFailed() bool {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	return room.joining && !room.loggedIn
+}
+
+// 等待d或ctx结束，ctx结束时返回false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
 	}
-	return out.Bytes()
 }
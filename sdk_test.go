@@ -1,74 +1,615 @@
-package douyulive
+package douyulive_test
 
 import (
 	"context"
-	"log"
-	"sort"
+	"errors"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	douyulive "douyu-barrage"
+	"douyu-barrage/fakeserver"
 )
 
 const aid = "xxx"
 const secret = "xxx"
+const roomID = 288016
+
+// 启动弹幕服务器与token接口，返回指向它们的Live
+func startFake(t *testing.T) (*douyulive.Live, *fakeserver.Server, *fakeserver.TokenHandler) {
+	t.Helper()
+	server, err := fakeserver.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	tokens := &fakeserver.TokenHandler{Aid: aid, Secret: secret, Token: "fake-token", Expire: 7200}
+	tokenServer := fakeserver.NewTokenServer(tokens)
+	t.Cleanup(tokenServer.Close)
+
+	live := &douyulive.Live{
+		AnalysisRoutineNum: 1,
+		OpenAPIBaseURL:     tokenServer.URL,
+		Reconnect:          douyulive.ReconnectPolicy{InitialBackoff: 10 * time.Millisecond},
+	}
+	return live, server, tokens
+}
+
+func closeLive(t *testing.T, live *douyulive.Live) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := live.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
 
 func TestLive_Start(t *testing.T) {
-	live := &Live{
-		Debug:              false, // 不输出日志
-		AnalysisRoutineNum: 1,     // 消息分析协程数量，默认为1，为1可以保证通知顺序与接收到消息顺序相同
-		LoginRespMessageHandler: func(roomID int, msg *LoginRespMessageModel) {
-			log.Printf("【登录消息】%s 登录成功", msg.NickName)
-		},
-		BarrageMessageHandler: func(roomID int, msg *BarrageMessageModel) {
-			log.Printf("【弹幕消息】%s 说：%s", msg.NickName, msg.Txt)
-		},
-		StormMessageHandler: func(roomID int, msg *StormMessage) {
-			log.Printf("【领取在线鱼丸消息】%s 在 %d 直播间领取%d鱼丸", msg.NickName, msg.RoomID, msg.Sil)
-		},
-		SendGiftMessageHandler: func(roomID int, msg *SendGiftMessage) {
-			log.Printf("【赠送礼物消息】%s在%d直播间赠送%d礼物，礼物ID: %d", msg.NickName, msg.RoomID, msg.GfCount, msg.GiftID)
-		},
-		SpecialUserMessageHandler: func(roomID int, msg *SpecialUserMessage) {
-			log.Printf("【用户进房通知消息】欢迎 %s 进入直播间%d", msg.NickName, msg.RoomID)
-		},
-		SwitchBroadcastMessageHandler: func(roomID int, msg *SwitchBroadcastMessage) {
-			if msg.Status == 0 {
-				log.Printf("【直播间开关播提醒消息】 %d直播间当前没有直播", msg.RoomID)
-			} else if msg.Status == 1 {
-				log.Printf("【直播间开关播提醒消息】 %d直播间当前正在直播", msg.RoomID)
+	live, server, tokens := startFake(t)
+	server.SetScript(
+		fakeserver.ChatMessage(1, "甲", "a/b@c"),
+		fakeserver.GiftMessage(2, "乙", 824, 3),
+		fakeserver.SwitchBroadcastMessage(true),
+	)
+
+	logins := make(chan *douyulive.LoginRespMessageModel, 1)
+	barrages := make(chan *douyulive.BarrageMessageModel, 1)
+	gifts := make(chan *douyulive.SendGiftMessage, 1)
+	switches := make(chan *douyulive.SwitchBroadcastMessage, 1)
+	live.LoginRespMessageHandler = func(roomID int, msg *douyulive.LoginRespMessageModel) {
+		logins <- msg
+	}
+	live.BarrageMessageHandler = func(roomID int, msg *douyulive.BarrageMessageModel) {
+		barrages <- msg
+	}
+	live.SendGiftMessageHandler = func(roomID int, msg *douyulive.SendGiftMessage) {
+		gifts <- msg
+	}
+	live.SwitchBroadcastMessageHandler = func(roomID int, msg *douyulive.SwitchBroadcastMessage) {
+		switches <- msg
+	}
+
+	live.Start(context.Background())
+	if err := live.Join(aid, secret, []*douyulive.HostServer{server.HostServer()}, roomID); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	select {
+	case msg := <-logins:
+		if msg.Type != douyulive.LoginRespType {
+			t.Errorf("login type = %q", msg.Type)
+		}
+	case <-timeout:
+		t.Fatal("没有收到登录响应")
+	}
+	select {
+	case msg := <-barrages:
+		if msg.RoomID != roomID || msg.UID != 1 || msg.NickName != "甲" || msg.Txt != "a/b@c" {
+			t.Errorf("barrage = %+v", msg)
+		}
+	case <-timeout:
+		t.Fatal("没有收到弹幕")
+	}
+	select {
+	case msg := <-gifts:
+		if msg.GiftID != 824 || msg.GfCount != 3 {
+			t.Errorf("gift = %+v", msg)
+		}
+	case <-timeout:
+		t.Fatal("没有收到礼物")
+	}
+	select {
+	case msg := <-switches:
+		if msg.Status != 1 {
+			t.Errorf("rss status = %d", msg.Status)
+		}
+	case <-timeout:
+		t.Fatal("没有收到开关播提醒")
+	}
+
+	logins2 := server.Received("loginreq")
+	if len(logins2) != 1 || logins2[0]["token"] != "fake-token" || logins2[0]["roomid"] != "288016" {
+		t.Errorf("loginreq = %v", logins2)
+	}
+	if n := len(server.Received("joingroup")); n != 1 {
+		t.Errorf("joingroup = %d", n)
+	}
+	if n := tokens.Calls(); n != 1 {
+		t.Errorf("token calls = %d", n)
+	}
+
+	closeLive(t, live)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.WaitFor(ctx, "logout", 1); err != nil {
+		t.Fatal("没有收到logout: ", err)
+	}
+}
+
+func TestLive_SharedToken(t *testing.T) {
+	live, server, tokens := startFake(t)
+	live.Start(context.Background())
+	defer closeLive(t, live)
+
+	if err := live.Join(aid, secret, []*douyulive.HostServer{server.HostServer()}, roomID, roomID+1, roomID+2); err != nil {
+		t.Fatal(err)
+	}
+	if n := tokens.Calls(); n != 1 {
+		t.Errorf("token calls = %d, want 1", n)
+	}
+	for _, msg := range server.Received("loginreq") {
+		if msg["token"] != "fake-token" {
+			t.Errorf("loginreq token = %q", msg["token"])
+		}
+	}
+}
+
+func TestLive_ReconnectAfterDrop(t *testing.T) {
+	live, server, _ := startFake(t)
+	server.SetScript(fakeserver.ChatMessage(1, "甲", "hello"))
+
+	barrages := make(chan *douyulive.BarrageMessageModel, 2)
+	live.BarrageMessageHandler = func(roomID int, msg *douyulive.BarrageMessageModel) {
+		barrages <- msg
+	}
+	live.Start(context.Background())
+	defer closeLive(t, live)
+	if err := live.Join(aid, secret, []*douyulive.HostServer{server.HostServer()}, roomID); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-barrages:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("第%d次连接没有收到弹幕", i+1)
+		}
+		if i == 0 {
+			server.DropConnections()
+		}
+	}
+	if n := len(server.Received("loginreq")); n != 2 {
+		t.Errorf("loginreq = %d, want 2", n)
+	}
+}
+
+func TestLive_Push(t *testing.T) {
+	live, server, _ := startFake(t)
+	barrages := make(chan *douyulive.BarrageMessageModel, 1)
+	live.BarrageMessageHandler = func(roomID int, msg *douyulive.BarrageMessageModel) {
+		barrages <- msg
+	}
+	live.Start(context.Background())
+	defer closeLive(t, live)
+	if err := live.Join(aid, secret, []*douyulive.HostServer{server.HostServer()}, roomID); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.WaitFor(ctx, "joingroup", 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := server.Push(roomID, fakeserver.ChatMessage(3, "丙", "推送")); n != 1 {
+		t.Fatalf("push = %d", n)
+	}
+	if n := server.Push(roomID+1, fakeserver.ChatMessage(3, "丙", "其他房间")); n != 0 {
+		t.Fatalf("push other room = %d", n)
+	}
+	select {
+	case msg := <-barrages:
+		if msg.Txt != "推送" {
+			t.Errorf("txt = %q", msg.Txt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("没有收到推送的弹幕")
+	}
+}
+
+func TestLive_MalformedFrame(t *testing.T) {
+	live, server, _ := startFake(t)
+	server.SetFaults(fakeserver.Faults{MalformedLogin: true})
+
+	events := make(chan *douyulive.ConnectionEvent, 8)
+	live.Reconnect.MaxAttempts = -1
+	live.ConnectionEventHandler = func(roomID int, event *douyulive.ConnectionEvent) {
+		events <- event
+	}
+	live.Start(context.Background())
+	defer closeLive(t, live)
+
+	// 登录响应为畸形帧，Join报告登录失败
+	err := live.Join(aid, secret, []*douyulive.HostServer{server.HostServer()}, roomID)
+	if !errors.Is(err, douyulive.ErrLengthMismatch) {
+		t.Fatalf("err = %v, want ErrLengthMismatch", err)
+	}
+	if rooms := live.Rooms(); len(rooms) != 0 {
+		t.Errorf("rooms = %v", rooms)
+	}
+	// 失败只通过Join的返回值通知一次，不再触发断线与放弃重连事件
+	timeout := time.After(200 * time.Millisecond)
+	for {
+		select {
+		case event := <-events:
+			if event.Type == douyulive.Disconnected || event.Type == douyulive.GaveUp {
+				t.Fatalf("Join报告失败后又通知了 %v: %v", event.Type, event.Err)
 			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func TestLive_SlowServer(t *testing.T) {
+	live, server, _ := startFake(t)
+	server.SetFaults(fakeserver.Faults{ReadDelay: 50 * time.Millisecond})
+	server.SetScript(fakeserver.ChatMessage(1, "甲", "slow"))
+
+	barrages := make(chan *douyulive.BarrageMessageModel, 1)
+	live.BarrageMessageHandler = func(roomID int, msg *douyulive.BarrageMessageModel) {
+		barrages <- msg
+	}
+	live.Start(context.Background())
+	defer closeLive(t, live)
+	if err := live.Join(aid, secret, []*douyulive.HostServer{server.HostServer()}, roomID); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-barrages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("没有收到弹幕")
+	}
+}
 
-		},
-		BroadcastRankMessageHandler: func(roomID int, msg *BroadcastRankMessage) {
-			log.Printf("【排行榜消息】 %d直播间当前排行榜消息: ", msg.RoomID)
-			sort.SliceStable(msg.ListAll, func(i, j int) bool {
-				return msg.ListAll[i].CurrentRank < msg.ListAll[j].CurrentRank
-			})
+func TestLive_BadSecret(t *testing.T) {
+	live, server, _ := startFake(t)
+	live.Start(context.Background())
+	defer closeLive(t, live)
+
+	err := live.Join(aid, "wrong", []*douyulive.HostServer{server.HostServer()}, roomID)
+	var apiErr *douyulive.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want APIError", err)
+	}
+	if apiErr.Code != douyulive.APICodeBadSignature || apiErr.Endpoint != fakeserver.TokenPath {
+		t.Errorf("api error = %+v", apiErr)
+	}
+	if !errors.Is(err, douyulive.ErrBadSignature) {
+		t.Errorf("errors.Is(%v, ErrBadSignature) = false", err)
+	}
+	if n := len(server.Received("loginreq")); n != 0 {
+		t.Errorf("loginreq = %d", n)
+	}
+}
+
+func TestLive_RawAndUnknownMessageHandler(t *testing.T) {
+	live, server, _ := startFake(t)
+	server.SetScript(
+		fakeserver.ChatMessage(1, "甲", "hello"),
+		map[string]string{"type": "blab", "uid": "1", "nn": "甲", "lbl": "3", "bl": "4", "bnn": "粉丝"},
+		map[string]string{"type": "anbc", "uid": "2", "nl": "5"},
+	)
+
+	type message struct {
+		msgType string
+		fields  map[string]string
+	}
+	raw := make(chan message, 16)
+	unknown := make(chan message, 16)
+	live.RawMessageHandler = func(roomID int, msgType string, fields map[string]string) {
+		raw <- message{msgType, fields}
+	}
+	live.UnknownMessageHandler = func(roomID int, msgType string, fields map[string]string) {
+		if msgType != "mrkl" {
+			unknown <- message{msgType, fields}
+		}
+	}
+	live.Start(context.Background())
+	defer closeLive(t, live)
+	if err := live.Join(aid, secret, []*douyulive.HostServer{server.HostServer()}, roomID); err != nil {
+		t.Fatal(err)
+	}
 
-			log.Print("总榜: ")
-			for _, list := range msg.ListAll {
-				log.Printf("用户: %s 排行榜第%d", list.NickName, list.CurrentRank)
+	for _, want := range []string{douyulive.LoginRespType, douyulive.BarrageRespType, "blab", "anbc"} {
+		select {
+		case msg := <-raw:
+			if msg.msgType != want || msg.fields["type"] != want {
+				t.Fatalf("raw message = %+v, want %s", msg, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("没有收到 %s", want)
+		}
+	}
+	for _, want := range []string{"blab", "anbc"} {
+		select {
+		case msg := <-unknown:
+			if msg.msgType != want {
+				t.Fatalf("unknown message = %+v, want %s", msg, want)
 			}
+			if want == "blab" && msg.fields["bnn"] != "粉丝" {
+				t.Errorf("blab fields = %v", msg.fields)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("没有收到 %s", want)
+		}
+	}
+}
+
+func TestLive_LoginError(t *testing.T) {
+	live, server, _ := startFake(t)
+	server.SetFaults(fakeserver.Faults{LoginErrorCode: douyulive.ServerCodeInvalidRoom})
+
+	codes := make(chan int64, 4)
+	live.ErrorMessageHandler = func(roomID int, msg *douyulive.ErrorMessage) {
+		codes <- msg.Code
+	}
+	// 登录失败只通过Join的返回值通知
+	async := make(chan error, 4)
+	live.ErrorHandler = func(roomID int, err error) {
+		async <- err
+	}
+	live.ConnectionEventHandler = func(roomID int, event *douyulive.ConnectionEvent) {
+		if event.Type == douyulive.Disconnected || event.Type == douyulive.GaveUp {
+			async <- event.Err
+		}
+	}
+	states := make(stateRecorder, 100)
+	live.StateChangeHandler = states.handle
+	live.Start(context.Background())
+	defer closeLive(t, live)
+
+	err := live.Join(aid, secret, []*douyulive.HostServer{server.HostServer()}, roomID)
+	if !errors.Is(err, douyulive.ErrInvalidRoomID) {
+		t.Fatalf("err = %v, want ErrInvalidRoomID", err)
+	}
+	var serverErr *douyulive.ServerError
+	if !errors.As(err, &serverErr) || serverErr.RoomID != roomID {
+		t.Fatalf("server error = %+v", serverErr)
+	}
+	states.wait(t, douyulive.StateFailed)
+	select {
+	case code := <-codes:
+		if code != douyulive.ServerCodeInvalidRoom {
+			t.Errorf("code = %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("没有收到error消息")
+	}
+	if rooms := live.Rooms(); len(rooms) != 0 {
+		t.Errorf("rooms = %v", rooms)
+	}
+	// 失败的房间已移出，仍可查询失败状态
+	status, ok := live.Status(roomID)
+	if !ok || status.State != douyulive.StateFailed || !errors.Is(status.LastError, douyulive.ErrInvalidRoomID) {
+		t.Errorf("Status() = %+v, %v", status, ok)
+	}
+	if n := len(server.Received("joingroup")); n != 0 {
+		t.Errorf("joingroup = %d", n)
+	}
+	select {
+	case err := <-async:
+		t.Errorf("Join报告失败后又异步通知了 %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestLive_LoginTimeout(t *testing.T) {
+	live, server, _ := startFake(t)
+	server.SetFaults(fakeserver.Faults{IgnoreLogin: true})
+	live.LoginTimeout = 100 * time.Millisecond
+	live.Start(context.Background())
+	defer closeLive(t, live)
+
+	err := live.Join(aid, secret, []*douyulive.HostServer{server.HostServer()}, roomID, roomID+1)
+	if !errors.Is(err, douyulive.ErrLoginTimeout) {
+		t.Fatalf("err = %v, want ErrLoginTimeout", err)
+	}
+	if rooms := live.Rooms(); len(rooms) != 0 {
+		t.Errorf("rooms = %v", rooms)
+	}
+
+	// 不等待登录响应
+	live.LoginTimeout = -1
+	if err := live.Join(aid, secret, []*douyulive.HostServer{server.HostServer()}, roomID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLive_Heartbeat(t *testing.T) {
+	live, server, _ := startFake(t)
+	live.HeartbeatInterval = 50 * time.Millisecond
+
+	rtts := make(chan time.Duration, 100)
+	live.HeartbeatRTTHandler = func(roomID int, rtt time.Duration) {
+		rtts <- rtt
+	}
+	live.Start(context.Background())
+	defer closeLive(t, live)
+	if err := live.Join(aid, secret, []*douyulive.HostServer{server.HostServer()}, roomID); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case rtt := <-rtts:
+		if rtt <= 0 || rtt > time.Second {
+			t.Errorf("rtt = %s", rtt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("没有收到心跳往返时间")
+	}
+
+	// 按间隔发送心跳，不能连续发送
+	time.Sleep(300 * time.Millisecond)
+	if n := len(server.Received(douyulive.HeartbeatType)); n < 3 || n > 15 {
+		t.Errorf("心跳次数 %d 与50ms间隔不符", n)
+	}
+}
 
-			log.Print("周榜: ")
-			for _, list := range msg.List {
-				log.Printf("用户: %s 排行榜第%d", list.NickName, list.CurrentRank)
+func TestLive_HeartbeatTimeout(t *testing.T) {
+	live, server, _ := startFake(t)
+	server.SetFaults(fakeserver.Faults{NoHeartbeatEcho: true})
+	live.HeartbeatInterval = time.Hour
+	live.HeartbeatTimeout = 200 * time.Millisecond
+
+	events := make(chan *douyulive.ConnectionEvent, 16)
+	live.ConnectionEventHandler = func(roomID int, event *douyulive.ConnectionEvent) {
+		events <- event
+	}
+	live.Start(context.Background())
+	defer closeLive(t, live)
+	if err := live.Join(aid, secret, []*douyulive.HostServer{server.HostServer()}, roomID); err != nil {
+		t.Fatal(err)
+	}
+
+	var disconnected bool
+	for {
+		select {
+		case event := <-events:
+			switch {
+			case event.Type == douyulive.Disconnected:
+				if !errors.Is(event.Err, douyulive.ErrHeartbeatTimeout) {
+					t.Fatalf("err = %v, want ErrHeartbeatTimeout", event.Err)
+				}
+				disconnected = true
+			case event.Type == douyulive.Connected && disconnected:
+				// 超时后重新连接
+				return
 			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("静默连接没有超时重连")
+		}
+	}
+}
+
+// 记录房间状态变化，按顺序等待
+type stateRecorder chan douyulive.RoomState
 
-			log.Print("日榜: ")
-			for _, list := range msg.ListDay {
-				log.Printf("用户: %s 排行榜第%d", list.NickName, list.CurrentRank)
+func (r stateRecorder) handle(roomID int, from, to douyulive.RoomState) {
+	r <- to
+}
+
+func (r stateRecorder) wait(t *testing.T, want douyulive.RoomState) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case state := <-r:
+			if state == want {
+				return
 			}
+		case <-timeout:
+			t.Fatalf("等待状态 %s 超时", want)
+		}
+	}
+}
+
+func TestLive_Status(t *testing.T) {
+	live, server, _ := startFake(t)
+	server.SetScript(fakeserver.ChatMessage(1, "甲", "hello"))
+	states := make(stateRecorder, 100)
+	live.StateChangeHandler = states.handle
+	live.Start(context.Background())
+	defer closeLive(t, live)
 
-		},
-		SuperBarrageMessageHandler: func(roomID int, msg *SuperBarrageMessage) {
-			log.Printf("【超级弹幕消息】 %d直播间超级弹幕消息: %s", msg.RoomID, msg.Content)
-		},
-		RoomGiftBarrageMessageHandler: func(roomID int, msg *RoomGiftBroadcastMessage) {
-			log.Printf("【房间内礼物广播消息】 %d直播间 %s赠送给 %s %d个 %d", msg.RoomID, msg.SendNickName, msg.DoneeNickName, msg.GiftCount, msg.GiftID)
-		},
-	}
-	ctx := context.Background()
-	live.Start(ctx)
-	_ = live.Join(aid, secret, "", 0, 288016)
-	go live.ReJoin(ctx)
-	live.Wait()
+	if _, ok := live.Status(roomID); ok {
+		t.Fatal("加入前 Status() ok = true")
+	}
+	if err := live.Join(aid, secret, []*douyulive.HostServer{server.HostServer()}, roomID); err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range []douyulive.RoomState{douyulive.StateConnecting, douyulive.StateConnected, douyulive.StateLoggedIn, douyulive.StateJoined} {
+		states.wait(t, state)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.WaitFor(ctx, "joingroup", 1); err != nil {
+		t.Fatal(err)
+	}
+	status, ok := live.Status(roomID)
+	if !ok {
+		t.Fatal("Status() ok = false")
+	}
+	if status.State != douyulive.StateJoined || status.Server.Address() != server.Addr() ||
+		status.ConnectedAt.IsZero() || status.Reconnects != 0 || status.LastError != nil {
+		t.Errorf("status = %+v", status)
+	}
+	if status.MessageCounts[douyulive.LoginRespType] != 1 {
+		t.Errorf("message counts = %v", status.MessageCounts)
+	}
+
+	server.DropConnections()
+	states.wait(t, douyulive.StateReconnecting)
+	states.wait(t, douyulive.StateJoined)
+	all := live.AllStatus()
+	if len(all) != 1 || all[0].RoomID != roomID {
+		t.Fatalf("AllStatus() = %v", all)
+	}
+	if all[0].Reconnects != 1 || all[0].LastError == nil || all[0].LastFrameAt.IsZero() {
+		t.Errorf("重连后 status = %+v", all[0])
+	}
+
+	if err := live.Remove(roomID); err != nil {
+		t.Fatal(err)
+	}
+	states.wait(t, douyulive.StateClosed)
+	// 移出的房间保留最后的状态，Forget后清除
+	if status, ok := live.Status(roomID); !ok || status.State != douyulive.StateClosed {
+		t.Errorf("移出后 Status() = %+v, %v", status, ok)
+	}
+	if all := live.AllStatus(); len(all) != 1 || all[0].State != douyulive.StateClosed {
+		t.Errorf("移出后 AllStatus() = %v", all)
+	}
+	live.Forget(roomID)
+	if _, ok := live.Status(roomID); ok {
+		t.Error("Forget后 Status() ok = true")
+	}
+	if all := live.AllStatus(); len(all) != 0 {
+		t.Errorf("Forget后 AllStatus() = %v", all)
+	}
+}
+
+func TestLive_Metrics(t *testing.T) {
+	live, server, _ := startFake(t)
+	server.SetScript(fakeserver.ChatMessage(1, "甲", "hello"))
+	metrics := douyulive.NewPrometheusMetrics()
+	live.Metrics = metrics
+
+	barrages := make(chan *douyulive.BarrageMessageModel, 2)
+	live.BarrageMessageHandler = func(roomID int, msg *douyulive.BarrageMessageModel) {
+		barrages <- msg
+	}
+	live.Start(context.Background())
+	defer closeLive(t, live)
+	if err := live.Join(aid, secret, []*douyulive.HostServer{server.HostServer()}, roomID); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-barrages:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("第%d次连接没有收到弹幕", i+1)
+		}
+		if i == 0 {
+			server.DropConnections()
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`douyu_frames_received_total{room="288016",type="chatmsg"} 2`,
+		`douyu_reconnects_total{room="288016"} 1`,
+		`douyu_token_refreshes_total{result="ok"} 1`,
+		`douyu_handler_duration_seconds_count{type="loginres"} 2`,
+		`douyu_queue_depth{worker="0"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("指标缺少 %s\n%s", want, body)
+		}
+	}
 }
@@ -0,0 +1,131 @@
+package douyulive
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 默认弹幕服务器
+const (
+	defaultServerHost = "openapi-danmu.douyu.com"
+	defaultServerPort = 80
+)
+
+// DefaultServerCooldown 连接失败的弹幕服务器默认暂停使用的时间
+const DefaultServerCooldown = 30 * time.Second
+
+// HostServer 弹幕服务器地址
+type HostServer struct {
+	Host    string `json:"host"`
+	Port    int    `json:"port"`
+	WssPort int    `json:"wss_port"`
+	WsPort  int    `json:"ws_port"`
+}
+
+// Address 返回 host:port 形式的TCP地址
+func (s *HostServer) Address() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// 过滤无效的地址，没有可用地址时使用默认弹幕服务器
+func normalizeServers(servers []*HostServer) []*HostServer {
+	list := make([]*HostServer, 0, len(servers))
+	for _, server := range servers {
+		if server == nil || server.Host == "" || server.Port == 0 {
+			continue
+		}
+		s := *server
+		list = append(list, &s)
+	}
+	if len(list) == 0 {
+		list = append(list, &HostServer{Host: defaultServerHost, Port: defaultServerPort})
+	}
+	return list
+}
+
+// 记录连接失败的弹幕服务器，在冷却期内优先使用其他服务器，所有房间共享
+type serverHealth struct {
+	mu       sync.Mutex
+	cooldown time.Duration
+	downTill map[string]time.Time // 地址 -> 冷却结束时间
+}
+
+func newServerHealth(cooldown time.Duration) *serverHealth {
+	if cooldown <= 0 {
+		cooldown = DefaultServerCooldown
+	}
+	return &serverHealth{
+		cooldown: cooldown,
+		downTill: make(map[string]time.Time),
+	}
+}
+
+func (h *serverHealth) available(server *HostServer) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	till, exist := h.downTill[server.Address()]
+	if !exist {
+		return true
+	}
+	if time.Now().After(till) {
+		delete(h.downTill, server.Address())
+		return true
+	}
+	return false
+}
+
+func (h *serverHealth) markDown(server *HostServer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.downTill[server.Address()] = time.Now().Add(h.cooldown)
+}
+
+func (h *serverHealth) markUp(server *HostServer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.downTill, server.Address())
+}
+
+// 按尝试顺序排列的候选服务器：从当前服务器开始轮转，冷却期内的服务器排在最后
+func (room *liveRoom) candidateServers() []int {
+	room.mu.Lock()
+	start := room.currentServerIndex
+	room.mu.Unlock()
+
+	n := len(room.hostServerList)
+	healthy := make([]int, 0, n)
+	var cooling []int
+	for i := 0; i < n; i++ {
+		index := (start + i) % n
+		if room.health.available(room.hostServerList[index]) {
+			healthy = append(healthy, index)
+		} else {
+			cooling = append(cooling, index)
+		}
+	}
+	return append(healthy, cooling...)
+}
+
+// 当前使用的弹幕服务器
+func (room *liveRoom) currentServer() *HostServer {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	return room.hostServerList[room.currentServerIndex]
+}
+
+// 弹幕服务器地址
+func (room *liveRoom) address() string {
+	return room.currentServer().Address()
+}
+
+// CurrentServer 返回房间当前连接的弹幕服务器
+func (live *Live) CurrentServer(roomID int) (*HostServer, bool) {
+	room, exist := live.rooms.get(roomID)
+	if !exist {
+		return nil, false
+	}
+	server := *room.currentServer()
+	return &server, true
+}
@@ -0,0 +1,98 @@
+package douyulive
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// 返回一个没有监听的本地地址
+func closedAddr(t *testing.T) net.Addr {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	addr := ln.Addr()
+	_ = ln.Close()
+	return addr
+}
+
+func TestLive_ServerFailover(t *testing.T) {
+	down := testServers(closedAddr(t))[0]
+	up := testServers(listenDiscard(t))[0]
+
+	live := &Live{ServerCooldown: time.Minute, TokenProvider: testToken}
+	live.Start(context.Background())
+	defer live.Close(context.Background())
+
+	if err := live.Join("aid", "secret", []*HostServer{down, up}, 1); err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+	server, ok := live.CurrentServer(1)
+	if !ok || server.Address() != up.Address() {
+		t.Fatalf("CurrentServer() = %v, want %s", server, up.Address())
+	}
+	if live.serverHealth.available(down) {
+		t.Fatalf("连接失败的服务器 %s 没有进入冷却期", down.Address())
+	}
+
+	// 冷却期内的服务器排在最后
+	if err := live.Join("aid", "secret", []*HostServer{down, up}, 2); err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+	room, _ := live.rooms.get(2)
+	if got := room.candidateServers(); len(got) != 2 || got[0] != 1 || got[1] != 0 {
+		t.Fatalf("candidateServers() = %v, want [1 0]", got)
+	}
+
+	if _, ok := live.CurrentServer(3); ok {
+		t.Fatal("CurrentServer() 不存在的房间 ok = true")
+	}
+}
+
+func TestLive_ServerAllDown(t *testing.T) {
+	live := &Live{TokenProvider: testToken}
+	live.Start(context.Background())
+	defer live.Close(context.Background())
+
+	servers := []*HostServer{testServers(closedAddr(t))[0], testServers(closedAddr(t))[0]}
+	if err := live.Join("aid", "secret", servers, 1); err == nil {
+		t.Fatal("Join() 所有服务器不可用 error = nil")
+	}
+	if rooms := live.Rooms(); len(rooms) != 0 {
+		t.Fatalf("Rooms() = %v, want empty", rooms)
+	}
+}
+
+func TestServerHealth(t *testing.T) {
+	health := newServerHealth(50 * time.Millisecond)
+	server := &HostServer{Host: "127.0.0.1", Port: 1}
+
+	health.markDown(server)
+	if health.available(server) {
+		t.Fatal("available() 冷却期内 = true")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !health.available(server) {
+		t.Fatal("available() 冷却期后 = false")
+	}
+
+	health.markDown(server)
+	health.markUp(server)
+	if !health.available(server) {
+		t.Fatal("available() markUp后 = false")
+	}
+}
+
+func TestNormalizeServers(t *testing.T) {
+	servers := normalizeServers([]*HostServer{nil, {Host: "a"}, {Host: "b", Port: 2}})
+	if len(servers) != 1 || servers[0].Address() != "b:2" {
+		t.Fatalf("normalizeServers() = %v", servers)
+	}
+	servers = normalizeServers(nil)
+	if len(servers) != 1 || servers[0].Host != defaultServerHost || servers[0].Port != defaultServerPort {
+		t.Fatalf("normalizeServers(nil) = %v", servers)
+	}
+}
@@ -0,0 +1,60 @@
+package douyulive
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 弹幕服务器error消息的错误码
+const (
+	ServerCodeOK           = 0   // 操作成功
+	ServerCodeDataTransfer = 51  // 数据传输出错
+	ServerCodeServerClosed = 52  // 服务器关闭
+	ServerCodeInvalidRoom  = 204 // 房间ID错误
+)
+
+// 可配合errors.Is判断错误码
+var (
+	ErrDataTransfer  = &ServerError{Code: ServerCodeDataTransfer}
+	ErrServerClosed  = &ServerError{Code: ServerCodeServerClosed}
+	ErrInvalidRoomID = &ServerError{Code: ServerCodeInvalidRoom}
+)
+
+// ErrLoginTimeout 超过LoginTimeout没有收到登录响应
+var ErrLoginTimeout = errors.New("等待登录响应超时")
+
+// ServerError 弹幕服务器通过error消息返回的错误
+type ServerError struct {
+	RoomID int
+	Code   int64
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("房间 %d 弹幕服务器返回错误 %d: %s", e.RoomID, e.Code, serverCodeText(e.Code))
+}
+
+// Is 错误码相同即视为相同的错误
+func (e *ServerError) Is(target error) bool {
+	t, ok := target.(*ServerError)
+	return ok && t.Code == e.Code
+}
+
+func serverCodeText(code int64) string {
+	switch code {
+	case ServerCodeOK:
+		return "操作成功"
+	case ServerCodeDataTransfer:
+		return "数据传输出错"
+	case ServerCodeServerClosed:
+		return "服务器关闭"
+	case ServerCodeInvalidRoom:
+		return "房间ID错误"
+	default:
+		return "未知错误"
+	}
+}
+
+// 重试也不会成功的错误，遇到后不再重连
+func isPermanent(err error) bool {
+	return errors.Is(err, ErrInvalidRoomID) || errors.Is(err, ErrBadSignature) || errors.Is(err, ErrForbidden)
+}
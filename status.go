@@ -0,0 +1,193 @@
+package douyulive
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RoomState 房间连接状态
+type RoomState int
+
+const (
+	StateIdle         RoomState = iota // 尚未连接
+	StateConnecting                    // 正在连接弹幕服务器
+	StateConnected                     // 已连接并发送登录请求
+	StateLoggedIn                      // 收到登录响应
+	StateJoined                        // 已入组，正在接收消息
+	StateReconnecting                  // 连接断开，等待重连
+	StateFailed                        // 登录失败或放弃重连，房间会被移出
+	StateClosed                        // 已移出或Live已关闭
+)
+
+func (s RoomState) String() string {
+	switch s {
+	case StateIdle:
+		return "Idle"
+	case StateConnecting:
+		return "Connecting"
+	case StateConnected:
+		return "Connected"
+	case StateLoggedIn:
+		return "LoggedIn"
+	case StateJoined:
+		return "Joined"
+	case StateReconnecting:
+		return "Reconnecting"
+	case StateFailed:
+		return "Failed"
+	case StateClosed:
+		return "Closed"
+	default:
+		return fmt.Sprintf("RoomState(%d)", int(s))
+	}
+}
+
+// RoomStatus 房间状态快照
+type RoomStatus struct {
+	RoomID        int
+	State         RoomState
+	Server        *HostServer       // 当前使用的弹幕服务器
+	ConnectedAt   time.Time         // 最近一次连接成功的时间
+	Reconnects    int               // 断线后尝试重连的次数
+	LastFrameAt   time.Time         // 最后收到消息的时间
+	RTT           time.Duration     // 最近一次心跳往返时间
+	MessageCounts map[string]uint64 // 按消息类型统计的收到的消息数
+	Dropped       uint64            // 队列满时被丢弃的消息数
+	LastError     error             // 最近一次错误
+}
+
+// 房间状态，由连接、接收与消息分析协程更新
+type roomStatus struct {
+	mu            sync.Mutex
+	state         RoomState
+	connectedAt   time.Time
+	reconnects    int
+	lastErr       error
+	messageCounts map[string]uint64
+	dropped       uint64
+	onChange      func(from, to RoomState) // 状态变化时调用，不持有锁
+}
+
+// 切换状态，状态变化时通知onChange
+func (s *roomStatus) set(to RoomState) {
+	s.mu.Lock()
+	from := s.state
+	if !validTransition(from, to) {
+		s.mu.Unlock()
+		return
+	}
+	s.state = to
+	if to == StateConnected {
+		s.connectedAt = time.Now()
+	}
+	onChange := s.onChange
+	s.mu.Unlock()
+
+	if onChange != nil {
+		onChange(from, to)
+	}
+}
+
+// 失败与关闭后不再变化，登录与入组只能依次发生
+func validTransition(from, to RoomState) bool {
+	switch {
+	case from == to, from == StateFailed, from == StateClosed:
+		return false
+	case to == StateLoggedIn:
+		return from == StateConnected
+	case to == StateJoined:
+		return from == StateLoggedIn
+	}
+	return true
+}
+
+func (s *roomStatus) setError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastErr = err
+}
+
+func (s *roomStatus) addReconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reconnects++
+}
+
+func (s *roomStatus) addDropped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropped++
+}
+
+func (s *roomStatus) countMessage(msgType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.messageCounts == nil {
+		s.messageCounts = make(map[string]uint64)
+	}
+	s.messageCounts[msgType]++
+}
+
+// 通知房间状态变化
+func (live *Live) emitStateChange(roomID int, from, to RoomState) {
+	if live.StateChangeHandler != nil {
+		live.StateChangeHandler(roomID, from, to)
+	}
+}
+
+// Status 返回房间当前的状态，房间不存在时返回false
+// 失败或已移出的房间保留最后的状态（StateFailed或StateClosed），直到重新加入或调用Forget
+func (live *Live) Status(roomID int) (*RoomStatus, bool) {
+	room, exist := live.rooms.lookup(roomID)
+	if !exist {
+		return nil, false
+	}
+	return room.snapshot(), true
+}
+
+// AllStatus 返回所有房间的状态，包括失败或已移出的房间，按房间ID排序
+func (live *Live) AllStatus() []*RoomStatus {
+	ids := live.rooms.allIDs()
+	statuses := make([]*RoomStatus, 0, len(ids))
+	for _, id := range ids {
+		if status, ok := live.Status(id); ok {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// Forget 清除失败或已移出房间保留的状态，之后Status不再返回这些房间
+func (live *Live) Forget(roomIDs ...int) {
+	for _, roomID := range roomIDs {
+		live.rooms.forget(roomID)
+	}
+}
+
+func (room *liveRoom) snapshot() *RoomStatus {
+	server := *room.currentServer()
+	status := &RoomStatus{
+		RoomID:      room.roomID,
+		Server:      &server,
+		LastFrameAt: room.lastFrameTime(),
+		RTT:         room.lastRTT(),
+	}
+
+	s := &room.status
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status.State = s.state
+	status.ConnectedAt = s.connectedAt
+	status.Reconnects = s.reconnects
+	status.LastError = s.lastErr
+	status.Dropped = s.dropped
+	status.MessageCounts = make(map[string]uint64, len(s.messageCounts))
+	for k, v := range s.messageCounts {
+		status.MessageCounts[k] = v
+	}
+	return status
+}
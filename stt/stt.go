@@ -0,0 +1,453 @@
+// Package stt 实现斗鱼弹幕协议使用的STT序列化格式
+//
+// 键值对表示为 key@=value/，数组表示为 item/item/，
+// 键和值中的 @ 转义为 @A，/ 转义为 @S，嵌套的数组和键值对序列化后再整体转义一次作为外层的值。
+package stt
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Escape 转义STT中的特殊字符
+func Escape(s string) string {
+	if !strings.ContainsAny(s, "@/") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s) + 8)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '@':
+			b.WriteString("@A")
+		case '/':
+			b.WriteString("@S")
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// Unescape 还原Escape转义的字符，无法识别的转义序列原样保留
+func Unescape(s string) string {
+	if !strings.Contains(s, "@") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '@' && i+1 < len(s) {
+			switch s[i+1] {
+			case 'A':
+				b.WriteByte('@')
+				i++
+				continue
+			case 'S':
+				b.WriteByte('/')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Marshal 将v序列化为STT格式
+//
+// 支持字符串、布尔、数值、以字符串为键的map以及切片和数组，可任意嵌套。
+// map按键排序输出以保证结果确定，type键固定排在最前面。
+// 返回值不包含协议要求的结尾空字符。
+func Marshal(v interface{}) ([]byte, error) {
+	s, err := encode(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+func encode(rv reflect.Value) (string, error) {
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return "", nil
+	case reflect.Interface, reflect.Ptr:
+		if rv.IsNil() {
+			return "", nil
+		}
+		return encode(rv.Elem())
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		if rv.Bool() {
+			return "1", nil
+		}
+		return "0", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, rv.Type().Bits()), nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return "", &UnsupportedTypeError{Type: rv.Type()}
+		}
+		keys := make([]string, 0, rv.Len())
+		for _, k := range rv.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sortKeys(keys)
+
+		var b strings.Builder
+		for _, k := range keys {
+			v, err := encode(rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key())))
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(Escape(k))
+			b.WriteString("@=")
+			b.WriteString(Escape(v))
+			b.WriteByte('/')
+		}
+		return b.String(), nil
+	case reflect.Slice, reflect.Array:
+		var b strings.Builder
+		for i := 0; i < rv.Len(); i++ {
+			v, err := encode(rv.Index(i))
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(Escape(v))
+			b.WriteByte('/')
+		}
+		return b.String(), nil
+	default:
+		return "", &UnsupportedTypeError{Type: rv.Type()}
+	}
+}
+
+// 按键排序，type固定排在最前面
+func sortKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i] == "type" || keys[j] == "type" {
+			return keys[i] == "type" && keys[j] != "type"
+		}
+		return keys[i] < keys[j]
+	})
+}
+
+// Unmarshal 解析STT格式的数据并存入v指向的值，末尾的空字符会被忽略
+//
+// v可以指向字符串、布尔、数值、结构体、以字符串为键的map、切片或interface{}，可任意嵌套。
+// 无法转换为目标类型的值保留零值，需要报告转换错误时使用Decoder并开启Strict。
+//
+// 结构体字段通过 stt 标签指定对应的键，如 `stt:"nn"`，没有标签时使用字段名，
+// 标签为 "-" 时忽略该字段，带有 inline 选项时该字段（结构体或结构体指针）从当前层级的键中解析。
+//
+// 解析到interface{}时，形如 k@=v/ 的值解析为map[string]interface{}，
+// 以 / 结尾的值解析为[]interface{}，其余解析为字符串。
+// STT格式本身无法区分以 / 结尾的文本和数组，需要精确类型时应使用具体的目标类型。
+func Unmarshal(data []byte, v interface{}) error {
+	return new(Decoder).Unmarshal(data, v)
+}
+
+// UnmarshalMap 将已拆分的键值对解析到v指向的结构体或map中，规则与Unmarshal相同
+func UnmarshalMap(m map[string]string, v interface{}) error {
+	return new(Decoder).UnmarshalMap(m, v)
+}
+
+// Decoder STT解析器
+type Decoder struct {
+	// Strict 为true时，值无法转换为目标类型会返回*ConversionError，其余字段仍会继续解析
+	Strict bool
+}
+
+// Unmarshal 同包级函数Unmarshal
+func (d *Decoder) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+	s := strings.TrimSuffix(string(data), "\x00")
+	st := &decodeState{strict: d.Strict}
+	st.decode("", s, rv.Elem())
+	return st.err
+}
+
+// UnmarshalMap 同包级函数UnmarshalMap
+func (d *Decoder) UnmarshalMap(m map[string]string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+	st := &decodeState{strict: d.Strict}
+	st.decodeFields("", m, rv.Elem())
+	return st.err
+}
+
+// 单次解析的状态，记录遇到的第一个错误
+type decodeState struct {
+	strict bool
+	err    error
+}
+
+func (st *decodeState) saveError(err error) {
+	if st.err == nil {
+		st.err = err
+	}
+}
+
+func (st *decodeState) conversionError(path, value string, t reflect.Type, err error) {
+	if st.strict {
+		st.saveError(&ConversionError{Key: path, Value: value, Type: t, Err: err})
+	}
+}
+
+func (st *decodeState) decode(path, s string, rv reflect.Value) {
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		st.decode(path, s, rv.Elem())
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			st.saveError(&UnsupportedTypeError{Type: rv.Type()})
+			return
+		}
+		rv.Set(reflect.ValueOf(decodeAny(s)))
+	case reflect.String:
+		rv.SetString(s)
+	case reflect.Bool:
+		if s == "" {
+			return
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			st.conversionError(path, s, rv.Type(), err)
+			return
+		}
+		rv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s == "" {
+			return
+		}
+		i, err := strconv.ParseInt(s, 10, rv.Type().Bits())
+		if err != nil {
+			st.conversionError(path, s, rv.Type(), err)
+			return
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s == "" {
+			return
+		}
+		u, err := strconv.ParseUint(s, 10, rv.Type().Bits())
+		if err != nil {
+			st.conversionError(path, s, rv.Type(), err)
+			return
+		}
+		rv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		if s == "" {
+			return
+		}
+		f, err := strconv.ParseFloat(s, rv.Type().Bits())
+		if err != nil {
+			st.conversionError(path, s, rv.Type(), err)
+			return
+		}
+		rv.SetFloat(f)
+	case reflect.Map, reflect.Struct:
+		m := make(map[string]string)
+		for _, item := range splitItems(s) {
+			k, v, ok := splitPair(item)
+			if !ok {
+				continue
+			}
+			m[k] = v
+		}
+		st.decodeFields(path, m, rv)
+	case reflect.Slice:
+		items := splitItems(s)
+		slice := reflect.MakeSlice(rv.Type(), len(items), len(items))
+		for i, item := range items {
+			st.decode(path+"["+strconv.Itoa(i)+"]", Unescape(item), slice.Index(i))
+		}
+		rv.Set(slice)
+	default:
+		st.saveError(&UnsupportedTypeError{Type: rv.Type()})
+	}
+}
+
+// 将键值对解析到map或结构体中
+func (st *decodeState) decodeFields(path string, m map[string]string, rv reflect.Value) {
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		st.decodeFields(path, m, rv.Elem())
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			st.saveError(&UnsupportedTypeError{Type: rv.Type()})
+			return
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+		for k, v := range m {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			st.decode(joinPath(path, k), v, elem)
+			rv.SetMapIndex(reflect.ValueOf(k).Convert(rv.Type().Key()), elem)
+		}
+	case reflect.Struct:
+		for _, f := range cachedFields(rv.Type()) {
+			field := rv.Field(f.index)
+			if f.inline {
+				st.decodeFields(path, m, field)
+				continue
+			}
+			v, ok := m[f.name]
+			if !ok {
+				continue
+			}
+			st.decode(joinPath(path, f.name), v, field)
+		}
+	default:
+		st.saveError(&UnsupportedTypeError{Type: rv.Type()})
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// 结构体字段信息
+type field struct {
+	name   string
+	index  int
+	inline bool
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+func cachedFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		tag := sf.Tag.Get("stt")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, field{
+			name:   name,
+			index:  i,
+			inline: opts == "inline",
+		})
+	}
+	f, _ := fieldCache.LoadOrStore(t, fields)
+	return f.([]field)
+}
+
+func decodeAny(s string) interface{} {
+	if !strings.HasSuffix(s, "/") {
+		return s
+	}
+	items := splitItems(s)
+	isMap := true
+	for _, item := range items {
+		if !strings.Contains(item, "@=") {
+			isMap = false
+			break
+		}
+	}
+	if isMap {
+		m := make(map[string]interface{}, len(items))
+		for _, item := range items {
+			k, v, _ := splitPair(item)
+			m[k] = decodeAny(v)
+		}
+		return m
+	}
+	list := make([]interface{}, len(items))
+	for i, item := range items {
+		list[i] = decodeAny(Unescape(item))
+	}
+	return list
+}
+
+// 按 / 拆分出未转义的元素，末尾的 / 可以省略
+func splitItems(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "/"), "/")
+}
+
+// 按 @= 拆分出键和值，并还原转义
+func splitPair(item string) (string, string, bool) {
+	i := strings.Index(item, "@=")
+	if i < 0 {
+		return "", "", false
+	}
+	return Unescape(item[:i]), Unescape(item[i+2:]), true
+}
+
+// UnsupportedTypeError 不支持序列化或解析的类型
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("stt: 不支持的类型 %s", e.Type)
+}
+
+// ConversionError 值无法转换为目标类型，仅在Strict模式下返回
+type ConversionError struct {
+	Key   string       // 值所在的键，嵌套时以 . 和 [下标] 连接
+	Value string       // 原始值
+	Type  reflect.Type // 目标类型
+	Err   error
+}
+
+func (e *ConversionError) Error() string {
+	return fmt.Sprintf("stt: 键 %s 的值 %q 无法转换为 %s: %v", e.Key, e.Value, e.Type, e.Err)
+}
+
+func (e *ConversionError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidUnmarshalError Unmarshal的参数不是非nil指针
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "stt: Unmarshal(nil)"
+	}
+	return fmt.Sprintf("stt: Unmarshal(非指针或nil %s)", e.Type)
+}
@@ -0,0 +1,305 @@
+package stt
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		raw     string
+		escaped string
+	}{
+		{"", ""},
+		{"hello", "hello"},
+		{"a/b", "a@Sb"},
+		{"a@b", "a@Ab"},
+		{"@S", "@AS"},
+		{"@A/", "@AA@S"},
+		{"k@=v/", "k@A=v@S"},
+	}
+	for _, tt := range tests {
+		if got := Escape(tt.raw); got != tt.escaped {
+			t.Errorf("Escape(%q) = %q, want %q", tt.raw, got, tt.escaped)
+		}
+		if got := Unescape(tt.escaped); got != tt.raw {
+			t.Errorf("Unescape(%q) = %q, want %q", tt.escaped, got, tt.raw)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+		want string
+	}{
+		{"键值对", map[string]string{"type": "chatmsg", "txt": "a/b", "nn": "x@y"}, "type@=chatmsg/nn@=x@Ay/txt@=a@Sb/"},
+		{"数组", []string{"a", "b/c"}, "a/b@Sc/"},
+		{"数值", map[string]interface{}{"rid": 288016, "hl": true}, "hl@=1/rid@=288016/"},
+		{"嵌套", map[string]interface{}{
+			"list": []map[string]string{{"uid": "1", "nickname": "a"}, {"uid": "2", "nickname": "b"}},
+		}, "list@=nickname@AA=a@ASuid@AA=1@AS@Snickname@AA=b@ASuid@AA=2@AS@S/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.in)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("Marshal() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Marshal(map[string]chan int{"a": nil}); err == nil {
+		t.Fatal("Marshal(chan) error = nil")
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	data := []byte("type@=ranklist/rid@=288016/list_all@=uid@AA=1@ASnickname@AA=a@AAAb@AS@Suid@AA=2@ASnickname@AA=c@AASd@AS@S/\x00")
+
+	var m map[string]string
+	if err := Unmarshal(data, &m); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if m["type"] != "ranklist" || m["rid"] != "288016" {
+		t.Fatalf("Unmarshal() = %v", m)
+	}
+
+	var list []map[string]string
+	if err := Unmarshal([]byte(m["list_all"]), &list); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := []map[string]string{
+		{"uid": "1", "nickname": "a@b"},
+		{"uid": "2", "nickname": "c/d"},
+	}
+	if !reflect.DeepEqual(list, want) {
+		t.Fatalf("Unmarshal() = %v, want %v", list, want)
+	}
+
+	var any interface{}
+	if err := Unmarshal(data, &any); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	wantAny := map[string]interface{}{
+		"type": "ranklist",
+		"rid":  "288016",
+		"list_all": []interface{}{
+			map[string]interface{}{"uid": "1", "nickname": "a@b"},
+			map[string]interface{}{"uid": "2", "nickname": "c/d"},
+		},
+	}
+	if !reflect.DeepEqual(any, wantAny) {
+		t.Fatalf("Unmarshal() = %v, want %v", any, wantAny)
+	}
+
+	if err := Unmarshal(data, m); err == nil {
+		t.Fatal("Unmarshal(非指针) error = nil")
+	}
+}
+
+// 生成大量包含特殊字符的字符串
+type sttString string
+
+func (sttString) Generate(r *rand.Rand, size int) reflect.Value {
+	const alphabet = "@/=ASa 中"
+	runes := []rune(alphabet)
+	n := r.Intn(size + 1)
+	b := make([]rune, n)
+	for i := range b {
+		b[i] = runes[r.Intn(len(runes))]
+	}
+	return reflect.ValueOf(sttString(b))
+}
+
+func TestRoundTrip(t *testing.T) {
+	config := &quick.Config{MaxCount: 500}
+
+	flat := func(in map[sttString]sttString) bool {
+		var out map[sttString]sttString
+		return roundTrip(t, in, &out) && len(in) == len(out) && (len(in) == 0 || reflect.DeepEqual(in, out))
+	}
+	if err := quick.Check(flat, config); err != nil {
+		t.Error(err)
+	}
+
+	list := func(in []sttString) bool {
+		var out []sttString
+		return roundTrip(t, in, &out) && len(in) == len(out) && (len(in) == 0 || reflect.DeepEqual(in, out))
+	}
+	if err := quick.Check(list, config); err != nil {
+		t.Error(err)
+	}
+
+	// 嵌套结构由quick生成时规模过大，改用小规模的随机数据
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		nested := make(map[sttString][]map[sttString][]sttString)
+		for j := r.Intn(4); j > 0; j-- {
+			var items []map[sttString][]sttString
+			for k := r.Intn(4); k > 0; k-- {
+				item := make(map[sttString][]sttString)
+				for l := r.Intn(4); l > 0; l-- {
+					item[randString(r)] = randStrings(r)
+				}
+				items = append(items, item)
+			}
+			nested[randString(r)] = items
+		}
+		var out map[sttString][]map[sttString][]sttString
+		if !roundTrip(t, nested, &out) {
+			t.Fatalf("round trip failed: %v", nested)
+		}
+
+		deep := make([][][][]sttString, r.Intn(4))
+		for j := range deep {
+			deep[j] = make([][][]sttString, r.Intn(4))
+			for k := range deep[j] {
+				deep[j][k] = make([][]sttString, r.Intn(4))
+				for l := range deep[j][k] {
+					deep[j][k][l] = randStrings(r)
+				}
+			}
+		}
+		var deepOut [][][][]sttString
+		if !roundTrip(t, deep, &deepOut) {
+			t.Fatalf("round trip failed: %v", deep)
+		}
+	}
+}
+
+func randString(r *rand.Rand) sttString {
+	return sttString("").Generate(r, 8).Interface().(sttString)
+}
+
+func randStrings(r *rand.Rand) []sttString {
+	s := make([]sttString, r.Intn(4))
+	for i := range s {
+		s[i] = randString(r)
+	}
+	return s
+}
+
+// 序列化后解析，再次序列化的结果应与第一次相同
+func roundTrip(t *testing.T, in, out interface{}) bool {
+	data, err := Marshal(in)
+	if err != nil {
+		t.Logf("Marshal(%v) error = %v", in, err)
+		return false
+	}
+	if err := Unmarshal(data, out); err != nil {
+		t.Logf("Unmarshal(%q) error = %v", data, err)
+		return false
+	}
+	again, err := Marshal(out)
+	if err != nil {
+		t.Logf("Marshal(%v) error = %v", out, err)
+		return false
+	}
+	if string(data) != string(again) {
+		t.Logf("round trip %q != %q", data, again)
+		return false
+	}
+	return true
+}
+
+type testEffect struct {
+	EID  int64 `stt:"eid"`
+	Type int64 `stt:"etp"`
+}
+
+type testRank struct {
+	UID      int64  `stt:"uid"`
+	NickName string `stt:"nickname"`
+}
+
+type testMessage struct {
+	Type     string            `stt:"type"`
+	RoomID   int64             `stt:"rid"`
+	Count    uint32            `stt:"gfcnt"`
+	Noble    bool              `stt:"nc"`
+	Txt      string            `stt:"txt"`
+	Effect   *testEffect       `stt:",inline"`
+	Badge    testRank          `stt:"badge"`
+	List     []*testRank       `stt:"list"`
+	Extra    map[string]string `stt:"extra"`
+	Ignored  string            `stt:"-"`
+	Untagged string
+}
+
+func TestUnmarshal_Struct(t *testing.T) {
+	data := []byte("type@=chatmsg/rid@=288016/gfcnt@=3/nc@=1/txt@=a@Sb/eid@=7/etp@=2/" +
+		"badge@=uid@A=9@Snickname@A=z@S/list@=uid@AA=1@ASnickname@AA=a@AS@Suid@AA=2@ASnickname@AA=b@AS@S/" +
+		"extra@=k@A=v@S/-@=x/Untagged@=u/\x00")
+
+	var msg testMessage
+	if err := Unmarshal(data, &msg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := testMessage{
+		Type:     "chatmsg",
+		RoomID:   288016,
+		Count:    3,
+		Noble:    true,
+		Txt:      "a/b",
+		Effect:   &testEffect{EID: 7, Type: 2},
+		Badge:    testRank{UID: 9, NickName: "z"},
+		List:     []*testRank{{UID: 1, NickName: "a"}, {UID: 2, NickName: "b"}},
+		Extra:    map[string]string{"k": "v"},
+		Untagged: "u",
+	}
+	if !reflect.DeepEqual(msg, want) {
+		t.Fatalf("Unmarshal() = %+v, want %+v", msg, want)
+	}
+
+	var fromMap testMessage
+	if err := UnmarshalMap(map[string]string{"rid": "1", "eid": "5", "list": "uid@A=3@S/"}, &fromMap); err != nil {
+		t.Fatalf("UnmarshalMap() error = %v", err)
+	}
+	if fromMap.RoomID != 1 || fromMap.Effect.EID != 5 || len(fromMap.List) != 1 || fromMap.List[0].UID != 3 {
+		t.Fatalf("UnmarshalMap() = %+v", fromMap)
+	}
+}
+
+func TestDecoder_Strict(t *testing.T) {
+	data := []byte("rid@=abc/gfcnt@=-1/nc@=2/txt@=ok/list@=uid@AA=x@AS@S/")
+
+	var lenient testMessage
+	if err := Unmarshal(data, &lenient); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if lenient.RoomID != 0 || lenient.Txt != "ok" {
+		t.Fatalf("Unmarshal() = %+v", lenient)
+	}
+
+	var strict testMessage
+	err := (&Decoder{Strict: true}).Unmarshal(data, &strict)
+	var convErr *ConversionError
+	if !errors.As(err, &convErr) {
+		t.Fatalf("Unmarshal() error = %v, want *ConversionError", err)
+	}
+	if strict.Txt != "ok" {
+		t.Fatalf("Unmarshal() 应继续解析其余字段, got %+v", strict)
+	}
+
+	for _, key := range []string{"rid", "gfcnt", "nc", "list[0].uid"} {
+		var msg testMessage
+		m := map[string]string{"rid": "1", "gfcnt": "1", "nc": "0", "list": "uid@A=1@S/"}
+		if key == "list[0].uid" {
+			m["list"] = "uid@A=x@S/"
+		} else {
+			m[key] = "bad"
+		}
+		err := (&Decoder{Strict: true}).UnmarshalMap(m, &msg)
+		if !errors.As(err, &convErr) || convErr.Key != key {
+			t.Errorf("UnmarshalMap(%s) error = %v, want key %s", key, err, key)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package douyulive
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTokenRefreshBefore 默认在token过期前多久刷新
+	DefaultTokenRefreshBefore = 5 * time.Minute
+	// 接口没有返回有效期时使用的有效期
+	defaultTokenExpire = 2 * time.Hour
+)
+
+// TokenProvider 提供登录弹幕服务器使用的token
+type TokenProvider interface {
+	Token(ctx context.Context, aid, secret string) (string, error)
+}
+
+// StaticTokenProvider 总是返回同一个token，用于测试或自行管理token的场景
+type StaticTokenProvider string
+
+// Token 返回固定的token
+func (p StaticTokenProvider) Token(ctx context.Context, aid, secret string) (string, error) {
+	return string(p), nil
+}
+
+// CachedTokenProvider 从斗鱼开放平台获取token，每个aid缓存一个token供所有房间共用，
+// 按接口返回的有效期提前刷新，同一aid的并发获取只请求一次
+type CachedTokenProvider struct {
+	BaseURL       string        // 开放平台地址，默认DefaultOpenAPIBaseURL
+	HTTPClient    *http.Client  // 默认DefaultHTTPClient
+	Retry         RetryPolicy   // 获取失败后的重试策略
+	RefreshBefore time.Duration // 过期前多久刷新，默认DefaultTokenRefreshBefore
+	Metrics       Metrics       // 记录每次请求token接口的结果，为nil时不记录
+
+	mu      sync.Mutex
+	entries map[string]*tokenEntry // 按aid缓存
+}
+
+type tokenEntry struct {
+	token     string
+	expireAt  time.Time
+	refreshAt time.Time     // 提前刷新的时间
+	fetching  chan struct{} // 不为nil时正在获取，获取结束后关闭
+	err       error         // 最近一次获取的错误
+}
+
+// Token 返回aid对应的token，缓存的token临近过期时重新获取
+func (p *CachedTokenProvider) Token(ctx context.Context, aid, secret string) (string, error) {
+	for {
+		p.mu.Lock()
+		if p.entries == nil {
+			p.entries = make(map[string]*tokenEntry)
+		}
+		entry, ok := p.entries[aid]
+		if !ok {
+			entry = &tokenEntry{}
+			p.entries[aid] = entry
+		}
+
+		now := time.Now()
+		if entry.token != "" && now.Before(entry.refreshAt) {
+			token := entry.token
+			p.mu.Unlock()
+			return token, nil
+		}
+
+		if fetching := entry.fetching; fetching != nil {
+			p.mu.Unlock()
+			select {
+			case <-fetching:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+
+			p.mu.Lock()
+			token, expireAt, err := entry.token, entry.expireAt, entry.err
+			p.mu.Unlock()
+			if err != nil {
+				if token != "" && time.Now().Before(expireAt) {
+					return token, nil
+				}
+				return "", err
+			}
+			continue
+		}
+
+		fetching := make(chan struct{})
+		entry.fetching = fetching
+		p.mu.Unlock()
+
+		info, err := p.fetch(ctx, aid, secret)
+
+		p.mu.Lock()
+		entry.fetching = nil
+		entry.err = err
+		if err == nil {
+			validity := tokenExpire(info.Expire)
+			refreshBefore := p.refreshBefore()
+			if refreshBefore > validity/2 {
+				refreshBefore = validity / 2
+			}
+			entry.token = info.Token
+			entry.expireAt = time.Now().Add(validity)
+			entry.refreshAt = entry.expireAt.Add(-refreshBefore)
+		}
+		token, expireAt := entry.token, entry.expireAt
+		close(fetching)
+		p.mu.Unlock()
+
+		if err != nil {
+			// 刷新失败但旧token尚未过期时继续使用旧token
+			if token != "" && time.Now().Before(expireAt) {
+				return token, nil
+			}
+			return "", err
+		}
+		return token, nil
+	}
+}
+
+// 请求token接口，失败时按重试策略重试，每次请求使用当前时间签名
+func (p *CachedTokenProvider) fetch(ctx context.Context, aid, secret string) (*TokenInfo, error) {
+	var info *TokenInfo
+	err := p.Retry.do(ctx, func() error {
+		var err error
+		info, err = requestTokenInfo(ctx, p.HTTPClient, p.BaseURL, aid, secret, time.Now())
+		metricsOrNop(p.Metrics).TokenRefresh(aid, err)
+		return err
+	})
+	return info, err
+}
+
+// Invalidate 丢弃aid缓存的token，下次调用Token时重新获取
+func (p *CachedTokenProvider) Invalidate(aid string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.entries[aid]; ok && entry.fetching == nil {
+		delete(p.entries, aid)
+	}
+}
+
+func (p *CachedTokenProvider) refreshBefore() time.Duration {
+	if p.RefreshBefore > 0 {
+		return p.RefreshBefore
+	}
+	return DefaultTokenRefreshBefore
+}
+
+// 接口返回的有效期，单位秒
+func tokenExpire(expire int) time.Duration {
+	if expire <= 0 {
+		return defaultTokenExpire
+	}
+	return time.Duration(expire) * time.Second
+}
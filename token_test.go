@@ -0,0 +1,146 @@
+package douyulive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 模拟token接口，每次返回不同的token
+func tokenServer(t *testing.T, expire int, delay time.Duration) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		time.Sleep(delay)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 0,
+			"msg":  "ok",
+			"data": map[string]interface{}{"token": fmt.Sprintf("token-%d-%s", n, r.URL.Query().Get("aid")), "expire": expire},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestCachedTokenProvider_Cache(t *testing.T) {
+	server, calls := tokenServer(t, 7200, 0)
+	provider := &CachedTokenProvider{BaseURL: server.URL}
+
+	for i := 0; i < 3; i++ {
+		token, err := provider.Token(context.Background(), "a", "s")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "token-1-a" {
+			t.Fatalf("token = %q", token)
+		}
+	}
+	token, err := provider.Token(context.Background(), "b", "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token-2-b" {
+		t.Fatalf("token = %q", token)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Fatalf("calls = %d, want 2", n)
+	}
+
+	provider.Invalidate("a")
+	if token, _ := provider.Token(context.Background(), "a", "s"); token != "token-3-a" {
+		t.Fatalf("invalidate token = %q", token)
+	}
+}
+
+func TestCachedTokenProvider_Concurrent(t *testing.T) {
+	server, calls := tokenServer(t, 7200, 50*time.Millisecond)
+	provider := &CachedTokenProvider{BaseURL: server.URL}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := provider.Token(context.Background(), "a", "s")
+			if err != nil || token != "token-1-a" {
+				t.Errorf("token = %q, err = %v", token, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Fatalf("calls = %d, want 1", n)
+	}
+}
+
+func TestCachedTokenProvider_RefreshBeforeExpire(t *testing.T) {
+	server, calls := tokenServer(t, 1, 0)
+	provider := &CachedTokenProvider{BaseURL: server.URL, RefreshBefore: time.Minute}
+
+	if token, _ := provider.Token(context.Background(), "a", "s"); token != "token-1-a" {
+		t.Fatalf("token = %q", token)
+	}
+	// 有效期1秒，最晚在过期前0.5秒刷新
+	time.Sleep(600 * time.Millisecond)
+	if token, _ := provider.Token(context.Background(), "a", "s"); token != "token-2-a" {
+		t.Fatalf("refreshed token = %q", token)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Fatalf("calls = %d, want 2", n)
+	}
+}
+
+func TestCachedTokenProvider_Error(t *testing.T) {
+	var fail int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&fail) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":0,"msg":"ok","data":{"token":"old","expire":1}}`))
+	}))
+	defer server.Close()
+	provider := &CachedTokenProvider{BaseURL: server.URL, Retry: RetryPolicy{MaxRetries: -1}}
+
+	if token, err := provider.Token(context.Background(), "a", "s"); err != nil || token != "old" {
+		t.Fatalf("token = %q, err = %v", token, err)
+	}
+
+	// 刷新失败时继续使用未过期的旧token
+	atomic.StoreInt32(&fail, 1)
+	time.Sleep(600 * time.Millisecond)
+	if token, err := provider.Token(context.Background(), "a", "s"); err != nil || token != "old" {
+		t.Fatalf("stale token = %q, err = %v", token, err)
+	}
+
+	// 过期后返回错误
+	time.Sleep(500 * time.Millisecond)
+	if _, err := provider.Token(context.Background(), "a", "s"); err == nil {
+		t.Fatal("expected error after expiry")
+	}
+}
+
+func TestCachedTokenProvider_Context(t *testing.T) {
+	server, _ := tokenServer(t, 7200, 300*time.Millisecond)
+	provider := &CachedTokenProvider{BaseURL: server.URL}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := provider.Token(ctx, "a", "s"); err == nil {
+		t.Fatal("expected context error")
+	}
+}
+
+func TestStaticTokenProvider(t *testing.T) {
+	token, err := StaticTokenProvider("fixed").Token(context.Background(), "a", "s")
+	if err != nil || token != "fixed" {
+		t.Fatalf("token = %q, err = %v", token, err)
+	}
+}
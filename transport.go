@@ -0,0 +1,20 @@
+package douyulive
+
+import (
+	"context"
+	"net"
+)
+
+// Transport 建立到弹幕服务器的连接，不同的实现在连接上传输相同的二进制消息帧
+// dialer为Live.Dialer，用于建立底层的TCP连接
+type Transport interface {
+	Dial(ctx context.Context, dialer *Dialer, server *HostServer) (net.Conn, error)
+}
+
+// TCPTransport 使用TCP直连弹幕服务器的Port端口，默认的传输方式
+type TCPTransport struct{}
+
+// Dial 实现Transport
+func (TCPTransport) Dial(ctx context.Context, dialer *Dialer, server *HostServer) (net.Conn, error) {
+	return dialer.Dial(ctx, server.Address())
+}
@@ -1,15 +1,17 @@
 package douyulive
 
 import (
+	"context"
 	"crypto/md5"
-	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -25,28 +27,67 @@ func StrToInt64(s string) int64 {
 	return i
 }
 
-func httpSend(url string) ([]byte, error) {
-	tr := &http.Transport{ //解决x509: certificate signed by unknown authority
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+func httpSend(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	if client == nil {
+		client = DefaultHTTPClient
 	}
-	client := &http.Client{Transport: tr}
-	resp, err := client.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, err
+		return nil, redactURLError(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, redactURLError(err)
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		douYuResp := new(DouYuResponse)
+		if json.Unmarshal(body, douYuResp) == nil {
+			apiErr.Code, apiErr.Msg = douYuResp.Code, douYuResp.Msg
+		}
+		return nil, apiErr
+	}
 	return body, nil
 }
 
-func httpGetDouYuToken(aid, secret string, currentTime time.Time) ([]byte, error) {
-	auth := Md5(fmt.Sprintf("/api/thirdPart/token?aid=%s&time=%d%s", aid, currentTime.Unix(), secret))
+// 请求失败的*url.Error包含完整的请求地址，去掉其中带有auth与token的查询参数
+func redactURLError(err error) error {
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return err
+	}
+	if i := strings.IndexByte(urlErr.URL, '?'); i >= 0 {
+		redacted := *urlErr
+		redacted.URL = urlErr.URL[:i]
+		return &redacted
+	}
+	return err
+}
+
+// DefaultOpenAPIBaseURL 斗鱼开放平台接口地址
+const DefaultOpenAPIBaseURL = "https://openapi.douyu.com"
+
+// token接口路径
+const tokenEndpoint = "/api/thirdPart/token"
+
+// 生成带auth签名的接口地址，auth为md5(接口路径?按key排序的参数+secret)
+func signedURL(baseURL, endpoint string, params url.Values, secret string) string {
+	query := params.Encode()
+	auth := Md5(fmt.Sprintf("%s?%s%s", endpoint, query, secret))
+	return fmt.Sprintf("%s%s?%s&auth=%s", strings.TrimSuffix(baseURL, "/"), endpoint, query, auth)
+}
+
+func httpGetDouYuToken(ctx context.Context, client *http.Client, baseURL, aid, secret string, currentTime time.Time) ([]byte, error) {
+	params := url.Values{}
+	params.Set("aid", aid)
+	params.Set("time", strconv.FormatInt(currentTime.Unix(), 10))
 
-	url := fmt.Sprintf("https://openapi.douyu.com/api/thirdPart/token?aid=%s&time=%d&auth=%s", aid, currentTime.Unix(), auth)
-	resp, err := httpSend(url)
+	resp, err := httpSend(ctx, client, signedURL(baseURL, tokenEndpoint, params, secret))
 	if err != nil {
 		return nil, err
 	}
@@ -55,31 +96,50 @@ func httpGetDouYuToken(aid, secret string, currentTime time.Time) ([]byte, error
 }
 
 func GenerateToken(aid, secret string, currentTime time.Time) (string, error) {
-	resp, err := httpGetDouYuToken(aid, secret, currentTime)
+	info, err := requestTokenInfo(context.Background(), DefaultHTTPClient, DefaultOpenAPIBaseURL, aid, secret, currentTime)
 	if err != nil {
 		return "", err
 	}
+	return info.Token, nil
+}
 
-	douYuTokenResp, err := MarshalDouYuData(resp, &TokenInfo{})
+// 从baseURL指向的开放平台获取token及有效期
+func requestTokenInfo(ctx context.Context, client *http.Client, baseURL, aid, secret string, currentTime time.Time) (*TokenInfo, error) {
+	if baseURL == "" {
+		baseURL = DefaultOpenAPIBaseURL
+	}
+	resp, err := httpGetDouYuToken(ctx, client, baseURL, aid, secret, currentTime)
 	if err != nil {
-		return "", err
+		return nil, withEndpoint(err, tokenEndpoint)
 	}
 
-	return douYuTokenResp.(*TokenInfo).Token, nil
+	info := new(TokenInfo)
+	if _, err := MarshalDouYuData(resp, info); err != nil {
+		return nil, withEndpoint(err, tokenEndpoint)
+	}
+	return info, nil
+}
 
+// 为接口错误补充接口路径
+func withEndpoint(err error, endpoint string) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Endpoint == "" {
+		apiErr.Endpoint = endpoint
+	}
+	return err
 }
 
 // 斗鱼接口数据返回
 type DouYuResponse struct {
-	Code int64       `json:"code"`
-	Msg  string      `json:"msg"`
-	Data interface{} `json:"data"`
+	Code int64           `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
 }
 
 // Token返回信息
 type TokenInfo struct {
 	Token  string `json:"token"`
-	Expire int    `json:"expire"` // 过期时间，2小时
+	Expire int    `json:"expire"` // 有效期，单位秒，一般为2小时
 }
 
 // 弹幕信息
@@ -106,40 +166,20 @@ type RoomInfo struct {
 	RoomName string `json:"room_name"` // 房间名
 }
 
+// MarshalDouYuData 检查接口返回的错误码，并将data解析到module，module为任意可被json解析的指针，返回module本身
 func MarshalDouYuData(resp []byte, module interface{}) (interface{}, error) {
 	douYuResp := new(DouYuResponse)
 	if err := json.Unmarshal(resp, douYuResp); err != nil {
 		return nil, err
 	}
-
-	d, err := json.Marshal(douYuResp.Data)
-	if err != nil {
-		return nil, err
+	if douYuResp.Code != APICodeOK {
+		return nil, &APIError{StatusCode: http.StatusOK, Code: douYuResp.Code, Msg: douYuResp.Msg}
 	}
-
-	switch module.(type) {
-	case *RoomInfo:
-		douYuRoomInfo := new(RoomInfo)
-		if err := json.Unmarshal(d, douYuRoomInfo); err != nil {
-			return nil, err
-		}
-		return douYuRoomInfo, nil
-	case *TokenInfo:
-		tokenInfo := new(TokenInfo)
-		if err := json.Unmarshal(d, tokenInfo); err != nil {
-			return nil, err
-		}
-		return tokenInfo, nil
-	case *BarrageInfo:
-		douYuBarrageInfo := new(BarrageInfo)
-		if err := json.Unmarshal(d, douYuBarrageInfo); err != nil {
-			return nil, err
-		}
-
-		return douYuBarrageInfo, nil
-
-	default:
-		return nil, errors.New("unsupported module type")
+	if len(douYuResp.Data) == 0 {
+		return module, nil
 	}
-
+	if err := json.Unmarshal(douYuResp.Data, module); err != nil {
+		return nil, err
+	}
+	return module, nil
 }
@@ -0,0 +1,49 @@
+package douyulive
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMarshalDouYuData(t *testing.T) {
+	// 未在SDK中定义的接口返回类型
+	type followInfo struct {
+		Followed bool  `json:"followed"`
+		Count    int64 `json:"count"`
+	}
+
+	info := new(followInfo)
+	got, err := MarshalDouYuData([]byte(`{"code":0,"msg":"ok","data":{"followed":true,"count":3}}`), info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != info || !info.Followed || info.Count != 3 {
+		t.Fatalf("got = %+v", got)
+	}
+
+	var list []*BarrageList
+	if _, err := MarshalDouYuData([]byte(`{"code":0,"data":[{"content":"a"},{"content":"b"}]}`), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[1].Content != "b" {
+		t.Fatalf("list = %+v", list)
+	}
+
+	token := new(TokenInfo)
+	if _, err := MarshalDouYuData([]byte(`{"code":0,"msg":"ok","data":null}`), token); err != nil || token.Token != "" {
+		t.Fatalf("token = %+v, err = %v", token, err)
+	}
+}
+
+func TestMarshalDouYuData_Error(t *testing.T) {
+	_, err := MarshalDouYuData([]byte(`{"code":429,"msg":"too many requests","data":{"token":"x"}}`), new(TokenInfo))
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := MarshalDouYuData([]byte(`not json`), new(TokenInfo)); err == nil {
+		t.Fatal("expected syntax error")
+	}
+	if _, err := MarshalDouYuData([]byte(`{"code":0,"data":{"rid":"x"}}`), new(RoomInfo)); err == nil {
+		t.Fatal("expected type error")
+	}
+}
@@ -0,0 +1,344 @@
+package douyulive
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WebSocket帧类型
+const (
+	wsOpContinuation byte = 0x0
+	wsOpText         byte = 0x1
+	wsOpBinary       byte = 0x2
+	wsOpClose        byte = 0x8
+	wsOpPing         byte = 0x9
+	wsOpPong         byte = 0xA
+)
+
+// 用于计算Sec-WebSocket-Accept
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket错误
+var (
+	ErrWebSocketHandshake = errors.New("WebSocket握手失败")
+	ErrWebSocketProtocol  = errors.New("WebSocket协议错误")
+)
+
+// WebSocketTransport 通过WebSocket连接弹幕服务器，每个二进制消息携带与TCP相同的消息帧
+// Secure为false时连接WsPort端口，为true时通过TLS连接WssPort端口
+type WebSocketTransport struct {
+	Secure    bool        // 是否使用wss
+	Path      string      // 请求路径，默认为 /
+	TLSConfig *tls.Config // wss使用的TLS配置，为nil时使用默认配置
+	Header    http.Header // 握手时附加的请求头
+}
+
+// Dial 实现Transport
+func (t *WebSocketTransport) Dial(ctx context.Context, dialer *Dialer, server *HostServer) (net.Conn, error) {
+	port := server.WsPort
+	if t.Secure {
+		port = server.WssPort
+	}
+	if port == 0 {
+		return nil, fmt.Errorf("%w: 弹幕服务器 %s 没有WebSocket端口", ErrWebSocketHandshake, server.Host)
+	}
+	addr := net.JoinHostPort(server.Host, strconv.Itoa(port))
+
+	conn, err := dialer.Dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	// TLS与升级握手期间遵循拨号超时与ctx的截止时间中较早的一个，ctx结束时关闭连接
+	deadline := time.Now().Add(dialer.timeout())
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	_ = conn.SetDeadline(deadline)
+	handshakeDone := make(chan struct{})
+	defer close(handshakeDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-handshakeDone:
+		}
+	}()
+
+	if t.Secure {
+		config := &tls.Config{}
+		if t.TLSConfig != nil {
+			config = t.TLSConfig.Clone()
+		}
+		if config.ServerName == "" {
+			config.ServerName = server.Host
+		}
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.Handshake(); err != nil {
+			_ = conn.Close()
+			return nil, handshakeError(ctx, err)
+		}
+		conn = tlsConn
+	}
+
+	wsConn, err := t.handshake(conn, addr)
+	if err != nil {
+		_ = conn.Close()
+		return nil, handshakeError(ctx, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return wsConn, nil
+}
+
+// ctx结束导致连接被关闭时返回ctx.Err()
+func handshakeError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// 发送升级请求并校验响应
+func (t *WebSocketTransport) handshake(conn net.Conn, host string) (*wsConn, error) {
+	path := t.Path
+	if path == "" {
+		path = "/"
+	}
+	scheme := "ws"
+	if t.Secure {
+		scheme = "wss"
+	}
+	req, err := http.NewRequest(http.MethodGet, scheme+"://"+host+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range t.Header {
+		req.Header[k] = v
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("%w: %s", ErrWebSocketHandshake, resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		return nil, fmt.Errorf("%w: Sec-WebSocket-Accept不匹配", ErrWebSocketHandshake)
+	}
+	return &wsConn{Conn: conn, br: br}, nil
+}
+
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsAcceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// WebSocket连接，Read读出的是二进制消息的内容，Write写入的数据作为一个二进制消息发送
+type wsConn struct {
+	net.Conn
+	br *bufio.Reader
+
+	// 当前数据帧的读取状态
+	remaining int64
+	masked    bool
+	mask      [4]byte
+	maskPos   int
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		h, err := readWSHeader(c.br)
+		if err != nil {
+			return 0, err
+		}
+		switch h.opcode {
+		case wsOpContinuation, wsOpText, wsOpBinary:
+			c.remaining, c.masked, c.mask, c.maskPos = h.length, h.masked, h.mask, 0
+		case wsOpPing, wsOpPong, wsOpClose:
+			payload, err := readWSControl(c.br, h)
+			if err != nil {
+				return 0, err
+			}
+			switch h.opcode {
+			case wsOpPing:
+				if err := c.writeFrame(wsOpPong, payload); err != nil {
+					return 0, err
+				}
+			case wsOpClose:
+				_ = c.writeFrame(wsOpClose, payload)
+				return 0, io.EOF
+			}
+		default:
+			return 0, fmt.Errorf("%w: 未知的帧类型 %d", ErrWebSocketProtocol, h.opcode)
+		}
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.br.Read(p)
+	if c.masked {
+		c.maskPos = maskBytes(c.mask, c.maskPos, p[:n])
+	}
+	c.remaining -= int64(n)
+	return n, err
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(wsOpBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close 发送关闭帧后关闭底层连接
+func (c *wsConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		_ = c.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+		_ = c.writeFrame(wsOpClose, []byte{0x03, 0xe8}) // 1000 正常关闭
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+// 客户端发送的帧必须使用掩码
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return writeWSFrame(c.Conn, opcode, payload, true)
+}
+
+type wsFrameHeader struct {
+	fin    bool
+	opcode byte
+	masked bool
+	mask   [4]byte
+	length int64
+}
+
+func readWSHeader(r io.Reader) (wsFrameHeader, error) {
+	var (
+		h   wsFrameHeader
+		buf [8]byte
+	)
+	if _, err := io.ReadFull(r, buf[:2]); err != nil {
+		return h, err
+	}
+	h.fin = buf[0]&0x80 != 0
+	h.opcode = buf[0] & 0x0f
+	h.masked = buf[1]&0x80 != 0
+	h.length = int64(buf[1] & 0x7f)
+
+	switch h.length {
+	case 126:
+		if _, err := io.ReadFull(r, buf[:2]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint16(buf[:2]))
+	case 127:
+		if _, err := io.ReadFull(r, buf[:8]); err != nil {
+			return h, err
+		}
+		h.length = int64(binary.BigEndian.Uint64(buf[:8]))
+		if h.length < 0 {
+			return h, fmt.Errorf("%w: 帧长度溢出", ErrWebSocketProtocol)
+		}
+	}
+	if h.masked {
+		if _, err := io.ReadFull(r, h.mask[:]); err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
+
+// 读取控制帧的内容，控制帧不能分片且不超过125字节
+func readWSControl(r io.Reader, h wsFrameHeader) ([]byte, error) {
+	if !h.fin || h.length > 125 {
+		return nil, fmt.Errorf("%w: 控制帧 %d 长度 %d", ErrWebSocketProtocol, h.opcode, h.length)
+	}
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	if h.masked {
+		maskBytes(h.mask, 0, payload)
+	}
+	return payload, nil
+}
+
+// 写入一个不分片的帧
+func writeWSFrame(w io.Writer, opcode byte, payload []byte, masked bool) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+
+	var maskBit byte
+	if masked {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = append(frame, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(frame[len(frame)-8:], uint64(n))
+	}
+
+	if !masked {
+		frame = append(frame, payload...)
+	} else {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		maskBytes(mask, 0, frame[start:])
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+// 对b进行掩码运算，pos为b在帧内容中的起始位置，返回下一个位置
+func maskBytes(mask [4]byte, pos int, b []byte) int {
+	for i := range b {
+		b[i] ^= mask[(pos+i)&3]
+	}
+	return (pos + len(b)) & 3
+}
@@ -0,0 +1,265 @@
+package douyulive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 完成WebSocket握手后交给serve处理
+func wsHandler(serve func(conn net.Conn, br *bufio.Reader)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+			http.Error(w, "not websocket", http.StatusBadRequest)
+			return
+		}
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + wsAcceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n"))
+		serve(conn, brw.Reader)
+	}
+}
+
+// 服务端读取一个客户端消息，客户端的帧必须带掩码
+func readClientMessage(br *bufio.Reader) (byte, []byte, error) {
+	h, err := readWSHeader(br)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !h.masked {
+		return 0, nil, errors.New("客户端帧没有掩码")
+	}
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		return 0, nil, err
+	}
+	maskBytes(h.mask, 0, payload)
+	return h.opcode, payload, nil
+}
+
+// 回显服务器：先发送ping，再把收到的消息拆成两个分片发回
+func wsEchoServer(pong chan<- []byte) http.HandlerFunc {
+	return wsHandler(func(conn net.Conn, br *bufio.Reader) {
+		for {
+			opcode, payload, err := readClientMessage(br)
+			if err != nil {
+				return
+			}
+			switch opcode {
+			case wsOpPong:
+				pong <- payload
+			case wsOpBinary:
+				_ = writeWSFrame(conn, wsOpPing, []byte("ping"), false)
+				half := len(payload) / 2
+				// 第一个分片不带FIN
+				var first bytes.Buffer
+				_ = writeWSFrame(&first, wsOpBinary, payload[:half], false)
+				b := first.Bytes()
+				b[0] &^= 0x80
+				_, _ = conn.Write(b)
+				_ = writeWSFrame(conn, wsOpContinuation, payload[half:], false)
+			case wsOpClose:
+				return
+			}
+		}
+	})
+}
+
+func serverPort(t *testing.T, srv *httptest.Server) int {
+	t.Helper()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatalf("Atoi() error = %v", err)
+	}
+	return p
+}
+
+func TestWebSocketTransport_Echo(t *testing.T) {
+	tests := []struct {
+		name   string
+		secure bool
+	}{
+		{"ws", false},
+		{"wss", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pong := make(chan []byte, 10)
+			var srv *httptest.Server
+			transport := &WebSocketTransport{Secure: tt.secure}
+			server := &HostServer{Host: "127.0.0.1"}
+			if tt.secure {
+				srv = httptest.NewTLSServer(wsEchoServer(pong))
+				transport.TLSConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+				server.WssPort = serverPort(t, srv)
+			} else {
+				srv = httptest.NewServer(wsEchoServer(pong))
+				server.WsPort = serverPort(t, srv)
+			}
+			defer srv.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			conn, err := transport.Dial(ctx, nil, server)
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer conn.Close()
+
+			bodies := [][]byte{
+				[]byte("type@=loginreq/roomid@=1/\x00"),
+				bytes.Repeat([]byte("a"), 300),
+				bytes.Repeat([]byte("b"), 70000),
+			}
+			reader := NewFrameReader(conn)
+			for _, body := range bodies {
+				if _, err := conn.Write(EncodeFrame(ClientMsgType, body)); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				msgType, got, err := reader.ReadFrame()
+				if err != nil {
+					t.Fatalf("ReadFrame() error = %v", err)
+				}
+				if msgType != ClientMsgType || !bytes.Equal(got, body) {
+					t.Fatalf("ReadFrame() = %d %d bytes, want %d bytes", msgType, len(got), len(body))
+				}
+				select {
+				case p := <-pong:
+					if string(p) != "ping" {
+						t.Fatalf("pong = %q, want ping", p)
+					}
+				case <-time.After(time.Second):
+					t.Fatal("没有收到pong")
+				}
+			}
+		})
+	}
+}
+
+func TestWebSocketTransport_HandshakeError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	transport := &WebSocketTransport{}
+	_, err := transport.Dial(context.Background(), nil, &HostServer{Host: "127.0.0.1", WsPort: serverPort(t, srv)})
+	if !errors.Is(err, ErrWebSocketHandshake) {
+		t.Fatalf("Dial() error = %v, want ErrWebSocketHandshake", err)
+	}
+
+	_, err = (&WebSocketTransport{Secure: true}).Dial(context.Background(), nil, &HostServer{Host: "127.0.0.1", WsPort: 1})
+	if !errors.Is(err, ErrWebSocketHandshake) {
+		t.Fatalf("Dial() 没有wss端口 error = %v, want ErrWebSocketHandshake", err)
+	}
+}
+
+// 服务器接受连接后不再响应，TLS与升级握手都应在拨号超时或ctx结束时返回
+func TestWebSocketTransport_HandshakeStall(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 8)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- conn
+		}
+	}()
+	defer func() {
+		ln.Close()
+		for conn := range accepted {
+			conn.Close()
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+	server := &HostServer{Host: "127.0.0.1", WsPort: port, WssPort: port}
+
+	for _, secure := range []bool{false, true} {
+		transport := &WebSocketTransport{Secure: secure}
+
+		start := time.Now()
+		_, err := transport.Dial(context.Background(), &Dialer{Timeout: 200 * time.Millisecond}, server)
+		if err == nil {
+			t.Fatalf("Secure=%v 拨号超时 Dial() 没有返回错误", secure)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("Secure=%v 拨号超时 Dial() 耗时 %v", secure, elapsed)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(200*time.Millisecond, cancel)
+		start = time.Now()
+		_, err = transport.Dial(ctx, nil, server)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Secure=%v ctx取消 Dial() error = %v, want context.Canceled", secure, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("Secure=%v ctx取消 Dial() 耗时 %v", secure, elapsed)
+		}
+	}
+}
+
+func TestLive_JoinWithTransport(t *testing.T) {
+	// 收到loginreq后响应登录并推送一条弹幕
+	srv := httptest.NewServer(wsHandler(func(conn net.Conn, br *bufio.Reader) {
+		for {
+			opcode, payload, err := readClientMessage(br)
+			if err != nil || opcode == wsOpClose {
+				return
+			}
+			_, body, err := NewFrameReader(bytes.NewReader(payload)).ReadFrame()
+			if err != nil {
+				return
+			}
+			if ByteToMsg(body)["type"] == "loginreq" {
+				_ = writeWSFrame(conn, wsOpBinary, loginRespFrame(), false)
+				frame := EncodeFrame(ServerMsgType, []byte(serializeMsg(map[string]string{"type": BarrageRespType, "txt": "via ws"})))
+				_ = writeWSFrame(conn, wsOpBinary, frame, false)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	received := make(chan string, 1)
+	live := &Live{
+		TokenProvider: testToken,
+		BarrageMessageHandler: func(roomID int, msg *BarrageMessageModel) {
+			received <- msg.Txt
+		},
+	}
+	live.Start(context.Background())
+	defer live.Close(context.Background())
+
+	servers := []*HostServer{{Host: "127.0.0.1", Port: 1, WsPort: serverPort(t, srv)}}
+	if err := live.JoinWithTransport(&WebSocketTransport{}, "aid", "secret", servers, 1); err != nil {
+		t.Fatalf("JoinWithTransport() error = %v", err)
+	}
+	select {
+	case txt := <-received:
+		if txt != "via ws" {
+			t.Fatalf("handler got %q, want via ws", txt)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("没有收到弹幕消息")
+	}
+}
@@ -0,0 +1,241 @@
+package douyulive
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMessageBuffer 每个消息分析协程默认的队列容量
+const DefaultMessageBuffer = 30
+
+// DefaultLowPriorityTypes OverflowDropLowPriority默认优先丢弃的消息类型
+var DefaultLowPriorityTypes = []string{SpecialUserRespType, BroadcastRankRespType}
+
+// OverflowPolicy 消息分析协程的队列已满时的处理策略
+type OverflowPolicy int
+
+const (
+	OverflowBlock           OverflowPolicy = iota // 等待队列有空位，期间停止读取连接，handler过慢时可能被服务器断开
+	OverflowDropNewest                            // 丢弃新收到的消息
+	OverflowDropOldest                            // 丢弃队列中最早的非登录响应与错误消息
+	OverflowDropLowPriority                       // 队列超过一半时丢弃LowPriorityTypes中的消息，队列满时其他消息等待
+)
+
+// OverflowEvent 开始或停止丢弃消息
+type OverflowEvent struct {
+	Worker   int    // 消息分析协程序号
+	Dropping bool   // true为开始丢弃，false为队列降到一半以下、消息不再需要丢弃时停止丢弃
+	Dropped  uint64 // 本次丢弃期间已丢弃的消息数
+}
+
+// 消息分析协程，同一房间的消息总是分配给同一个协程，以保证房间内的通知顺序
+type analysisWorker struct {
+	index           int
+	chSocketMessage chan *socketMessage
+	mu              sync.Mutex // OverflowDropOldest时放入消息需持有，保证重建队列期间没有其他消息放入
+	processed       uint64     // 已处理的消息数，原子操作
+	dropped         uint64     // 队列满时丢弃的消息数，原子操作
+	episodeDropped  uint64     // 本次丢弃期间丢弃的消息数，原子操作
+	dropping        int32      // 是否正在丢弃，原子操作
+}
+
+func newAnalysisWorker(index, buffer int) *analysisWorker {
+	return &analysisWorker{
+		index:           index,
+		chSocketMessage: make(chan *socketMessage, buffer),
+	}
+}
+
+// 根据房间ID选择消息分析协程
+func (live *Live) workerFor(roomID int) *analysisWorker {
+	index := roomID % len(live.workers)
+	if index < 0 {
+		index += len(live.workers)
+	}
+	return live.workers[index]
+}
+
+// 队列容量
+func (live *Live) messageBuffer() int {
+	if live.MessageBuffer > 0 {
+		return live.MessageBuffer
+	}
+	return DefaultMessageBuffer
+}
+
+// 低优先级消息类型集合
+func (live *Live) lowPriorityTypes() map[string]bool {
+	types := live.LowPriorityTypes
+	if types == nil {
+		types = DefaultLowPriorityTypes
+	}
+	set := make(map[string]bool, len(types))
+	for _, msgType := range types {
+		set[msgType] = true
+	}
+	return set
+}
+
+// 登录响应与错误消息不会被丢弃，队列满时等待放入，OverflowDropOldest时丢弃队列中其他消息腾出空位
+func isControlMessage(msgType string) bool {
+	return msgType == LoginRespType || msgType == ErrorRespType
+}
+
+// 按OverflowPolicy将消息放入房间对应的队列，需要等待时ctx结束返回ctx.Err()
+func (live *Live) enqueue(ctx context.Context, message *socketMessage) error {
+	worker := live.workerFor(message.roomID)
+	msgType := message.body["type"]
+	// 放入时同样报告队列深度，handler阻塞、不再取出消息时指标也能反映积压
+	defer func() { live.metrics.QueueDepth(worker.index, len(worker.chSocketMessage)) }()
+	// 放入前队列不到一半才视为已恢复，避免队列在满与未满之间反复时频繁通知
+	lowWater := len(worker.chSocketMessage) < (cap(worker.chSocketMessage)+1)/2
+
+	policy := live.OverflowPolicy
+	if isControlMessage(msgType) && policy != OverflowDropOldest {
+		policy = OverflowBlock
+	}
+	switch policy {
+	case OverflowDropNewest:
+		select {
+		case worker.chSocketMessage <- message:
+			live.resumeQueue(worker, lowWater)
+		default:
+			live.dropMessage(worker, message)
+		}
+		return nil
+	case OverflowDropOldest:
+		return live.enqueueDropOldest(ctx, worker, message)
+	case OverflowDropLowPriority:
+		if live.lowPriority[msgType] && len(worker.chSocketMessage) >= (cap(worker.chSocketMessage)+1)/2 {
+			live.dropMessage(worker, message)
+			return nil
+		}
+	}
+
+	select {
+	case worker.chSocketMessage <- message:
+		live.resumeQueue(worker, lowWater)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 队列满时丢弃最早的非登录响应与错误消息后放入，队列中全是这两类消息时等待
+// 持有worker.mu放入，消息分析协程只会取出消息，重建队列时放回不会阻塞
+func (live *Live) enqueueDropOldest(ctx context.Context, worker *analysisWorker, message *socketMessage) error {
+	worker.mu.Lock()
+	defer worker.mu.Unlock()
+
+	lowWater := len(worker.chSocketMessage) < (cap(worker.chSocketMessage)+1)/2
+	select {
+	case worker.chSocketMessage <- message:
+		live.resumeQueue(worker, lowWater)
+		return nil
+	default:
+	}
+
+	// 取出队列中的消息，去掉最早的可丢弃消息后按原顺序放回，期间消息分析协程取走的消息本就在最前面
+	queued := make([]*socketMessage, 0, cap(worker.chSocketMessage))
+drain:
+	for len(queued) < cap(worker.chSocketMessage) {
+		select {
+		case m := <-worker.chSocketMessage:
+			queued = append(queued, m)
+		default:
+			break drain
+		}
+	}
+	evicted := false
+	for i, m := range queued {
+		if !isControlMessage(m.body["type"]) {
+			live.dropMessage(worker, m)
+			queued = append(queued[:i], queued[i+1:]...)
+			evicted = true
+			break
+		}
+	}
+	for _, m := range queued {
+		live.putBack(worker, m)
+	}
+	if evicted {
+		// 丢弃旧消息腾出的空位不代表队列已恢复
+		live.putBack(worker, message)
+		return nil
+	}
+
+	select {
+	case worker.chSocketMessage <- message:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 重建队列时放回消息，不等待，队列已满时丢弃
+func (live *Live) putBack(worker *analysisWorker, message *socketMessage) {
+	select {
+	case worker.chSocketMessage <- message:
+	default:
+		live.dropMessage(worker, message)
+	}
+}
+
+// 记录丢弃的消息，开始丢弃时通知OverflowHandler
+func (live *Live) dropMessage(worker *analysisWorker, message *socketMessage) {
+	atomic.AddUint64(&worker.dropped, 1)
+	live.metrics.MessageDropped(message.roomID, message.body["type"])
+	if room, exist := live.rooms.get(message.roomID); exist {
+		room.status.addDropped()
+	}
+
+	if atomic.CompareAndSwapInt32(&worker.dropping, 0, 1) {
+		atomic.StoreUint64(&worker.episodeDropped, 1)
+		live.log.warn("消息队列已满，开始丢弃消息", "worker", worker.index)
+		live.emitOverflowEvent(&OverflowEvent{Worker: worker.index, Dropping: true, Dropped: 1})
+		return
+	}
+	atomic.AddUint64(&worker.episodeDropped, 1)
+}
+
+// 消息没有丢弃其他消息就放入了队列，且放入前队列不到一半时，
+// 之前在丢弃消息则通知OverflowHandler已停止丢弃
+func (live *Live) resumeQueue(worker *analysisWorker, lowWater bool) {
+	if !lowWater || atomic.LoadInt32(&worker.dropping) == 0 || !atomic.CompareAndSwapInt32(&worker.dropping, 1, 0) {
+		return
+	}
+	dropped := atomic.LoadUint64(&worker.episodeDropped)
+	live.log.info("消息队列恢复，停止丢弃消息", "worker", worker.index, "dropped", dropped)
+	live.emitOverflowEvent(&OverflowEvent{Worker: worker.index, Dropping: false, Dropped: dropped})
+}
+
+func (live *Live) emitOverflowEvent(event *OverflowEvent) {
+	if live.OverflowHandler != nil {
+		live.OverflowHandler(event)
+	}
+}
+
+// WorkerStat 消息分析协程的队列状态
+type WorkerStat struct {
+	Index     int    // 协程序号
+	QueueLen  int    // 队列中等待处理的消息数
+	QueueCap  int    // 队列容量
+	Processed uint64 // 已处理的消息数
+	Dropped   uint64 // 队列满时按OverflowPolicy丢弃的消息数
+}
+
+// WorkerStats 返回各消息分析协程的队列状态
+func (live *Live) WorkerStats() []WorkerStat {
+	stats := make([]WorkerStat, 0, len(live.workers))
+	for i, worker := range live.workers {
+		stats = append(stats, WorkerStat{
+			Index:     i,
+			QueueLen:  len(worker.chSocketMessage),
+			QueueCap:  cap(worker.chSocketMessage),
+			Processed: atomic.LoadUint64(&worker.processed),
+			Dropped:   atomic.LoadUint64(&worker.dropped),
+		})
+	}
+	return stats
+}
@@ -0,0 +1,394 @@
+package douyulive
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLive_WorkerOrder(t *testing.T) {
+	const (
+		rooms    = 8
+		messages = 200
+	)
+
+	var (
+		mu       sync.Mutex
+		received = make(map[int][]int)
+		done     sync.WaitGroup
+	)
+	done.Add(rooms * messages)
+
+	live := &Live{
+		AnalysisRoutineNum: 3,
+		BarrageMessageHandler: func(roomID int, msg *BarrageMessageModel) {
+			mu.Lock()
+			received[roomID] = append(received[roomID], int(msg.CID))
+			mu.Unlock()
+			done.Done()
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	live.Start(ctx)
+
+	// 每个房间一个发送协程，模拟各自的receive
+	for roomID := 1; roomID <= rooms; roomID++ {
+		go func(roomID int) {
+			ch := live.workerFor(roomID).chSocketMessage
+			for i := 0; i < messages; i++ {
+				ch <- &socketMessage{roomID: roomID, body: map[string]string{"type": BarrageRespType, "cid": strconv.Itoa(i)}}
+			}
+		}(roomID)
+	}
+	done.Wait()
+	cancel()
+	live.Wait()
+
+	for roomID := 1; roomID <= rooms; roomID++ {
+		for i, cid := range received[roomID] {
+			if cid != i {
+				t.Fatalf("房间 %d 第 %d 条消息为 %d，顺序错误", roomID, i, cid)
+			}
+		}
+	}
+
+	var processed uint64
+	for _, stat := range live.WorkerStats() {
+		processed += stat.Processed
+		if stat.QueueCap != DefaultMessageBuffer {
+			t.Fatalf("WorkerStats() QueueCap = %d, want %d", stat.QueueCap, DefaultMessageBuffer)
+		}
+	}
+	if processed != rooms*messages {
+		t.Fatalf("WorkerStats() Processed = %d, want %d", processed, rooms*messages)
+	}
+}
+
+func TestLive_WorkerIsolation(t *testing.T) {
+	block := make(chan struct{})
+	other := make(chan struct{}, 1)
+
+	live := &Live{
+		AnalysisRoutineNum: 2,
+		BarrageMessageHandler: func(roomID int, msg *BarrageMessageModel) {
+			if roomID == 2 {
+				<-block
+				return
+			}
+			other <- struct{}{}
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	live.Start(ctx)
+	defer func() {
+		close(block)
+		cancel()
+		live.Wait()
+	}()
+
+	live.workerFor(2).chSocketMessage <- &socketMessage{roomID: 2, body: map[string]string{"type": BarrageRespType}}
+	live.workerFor(3).chSocketMessage <- &socketMessage{roomID: 3, body: map[string]string{"type": BarrageRespType}}
+
+	select {
+	case <-other:
+	case <-time.After(time.Second):
+		t.Fatal("房间 2 阻塞了其他分片的房间")
+	}
+}
+
+// 记录最近一次报告的队列深度
+type depthMetrics struct {
+	NopMetrics
+	mu    sync.Mutex
+	depth map[int]int
+}
+
+func (m *depthMetrics) QueueDepth(worker int, depth int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.depth[worker] = depth
+}
+
+func (m *depthMetrics) get(worker int) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.depth[worker]
+}
+
+func TestLive_QueueDepthWhileHandlerBlocked(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{}, 1)
+	metrics := &depthMetrics{depth: make(map[int]int)}
+	live := &Live{
+		Metrics: metrics,
+		BarrageMessageHandler: func(roomID int, msg *BarrageMessageModel) {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-block
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	live.Start(ctx)
+	defer func() {
+		close(block)
+		cancel()
+		live.Wait()
+	}()
+
+	message := func() *socketMessage {
+		return &socketMessage{roomID: 1, body: map[string]string{"type": BarrageRespType}}
+	}
+	if err := live.enqueue(ctx, message()); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	// handler阻塞期间没有取出消息，放入时仍更新队列深度
+	for i := 1; i <= 3; i++ {
+		if err := live.enqueue(ctx, message()); err != nil {
+			t.Fatal(err)
+		}
+		if depth := metrics.get(0); depth != i {
+			t.Fatalf("第%d条消息放入后 QueueDepth = %d", i, depth)
+		}
+	}
+}
+
+func TestLive_OverflowPolicy(t *testing.T) {
+	msg := func(msgType string, cid int) *socketMessage {
+		return &socketMessage{roomID: 1, body: map[string]string{"type": msgType, "cid": strconv.Itoa(cid)}}
+	}
+	chats := func(n int) []*socketMessage {
+		msgs := make([]*socketMessage, n)
+		for i := range msgs {
+			msgs[i] = msg(BarrageRespType, i)
+		}
+		return msgs
+	}
+	started := []OverflowEvent{{Worker: 0, Dropping: true, Dropped: 1}}
+	tests := []struct {
+		name    string
+		policy  OverflowPolicy
+		send    []*socketMessage // 第一条消息阻塞handler，之后的消息填充容量为2的队列
+		want    []string         // handler收到的cid
+		dropped uint64
+		events  []OverflowEvent
+	}{
+		{"drop newest", OverflowDropNewest, chats(4), []string{"0", "1", "2"}, 1, started},
+		{"drop newest burst", OverflowDropNewest, chats(6), []string{"0", "1", "2"}, 3, started},
+		{"drop oldest", OverflowDropOldest, chats(4), []string{"0", "2", "3"}, 1, started},
+		{"drop oldest burst", OverflowDropOldest, chats(6), []string{"0", "4", "5"}, 3, started},
+		{"drop oldest keeps control message", OverflowDropOldest,
+			[]*socketMessage{msg(BarrageRespType, 0), msg(LoginRespType, 1), msg(BarrageRespType, 2), msg(BarrageRespType, 3)},
+			[]string{"0", "1", "3"}, 1, started},
+		{"drop oldest control message evicts older chat", OverflowDropOldest,
+			[]*socketMessage{msg(BarrageRespType, 0), msg(BarrageRespType, 1), msg(BarrageRespType, 2), msg(LoginRespType, 3)},
+			[]string{"0", "2", "3"}, 1, started},
+		{"drop oldest waits behind control messages", OverflowDropOldest,
+			[]*socketMessage{msg(BarrageRespType, 0), msg(LoginRespType, 1), msg(ErrorRespType, 2), msg(BarrageRespType, 3)},
+			[]string{"0", "1", "2", "3"}, 0, nil},
+		{"drop low priority", OverflowDropLowPriority,
+			[]*socketMessage{msg(BarrageRespType, 0), msg(BarrageRespType, 1), msg(SpecialUserRespType, 2), msg(SendGiftRespType, 3)},
+			[]string{"0", "1", "3"}, 1, started},
+		{"control message", OverflowDropNewest,
+			[]*socketMessage{msg(BarrageRespType, 0), msg(BarrageRespType, 1), msg(BarrageRespType, 2), msg(LoginRespType, 3)},
+			[]string{"0", "1", "2", "3"}, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu       sync.Mutex
+				received []string
+				events   []OverflowEvent
+			)
+			started := make(chan struct{}, 1)
+			release := make(chan struct{})
+			record := func(fields map[string]string) {
+				mu.Lock()
+				received = append(received, fields["cid"])
+				first := len(received) == 1
+				mu.Unlock()
+				if first {
+					started <- struct{}{}
+					<-release
+				}
+			}
+			live := &Live{
+				MessageBuffer:  2,
+				OverflowPolicy: tt.policy,
+				RawMessageHandler: func(roomID int, msgType string, fields map[string]string) {
+					record(fields)
+				},
+				OverflowHandler: func(event *OverflowEvent) {
+					mu.Lock()
+					events = append(events, *event)
+					mu.Unlock()
+				},
+			}
+			live.Start(context.Background())
+
+			if err := live.enqueue(context.Background(), tt.send[0]); err != nil {
+				t.Fatal(err)
+			}
+			<-started
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for _, m := range tt.send[1:] {
+					if err := live.enqueue(context.Background(), m); err != nil {
+						t.Error(err)
+					}
+				}
+			}()
+			if tt.dropped > 0 {
+				// 丢弃策略不会阻塞
+				<-done
+			}
+			close(release)
+			<-done
+			if err := live.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if strings.Join(received, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("received = %v, want %v", received, tt.want)
+			}
+			if got := live.WorkerStats()[0].Dropped; got != tt.dropped {
+				t.Errorf("Dropped = %d, want %d", got, tt.dropped)
+			}
+			if !reflect.DeepEqual(events, tt.events) {
+				t.Errorf("events = %+v, want %+v", events, tt.events)
+			}
+		})
+	}
+}
+
+func TestLive_OverflowResume(t *testing.T) {
+	for _, policy := range []OverflowPolicy{OverflowDropNewest, OverflowDropOldest, OverflowDropLowPriority} {
+		release := make(chan struct{})
+		started := make(chan struct{}, 1)
+		var (
+			mu     sync.Mutex
+			events []OverflowEvent
+		)
+		live := &Live{
+			MessageBuffer:  2,
+			OverflowPolicy: policy,
+			RawMessageHandler: func(roomID int, msgType string, fields map[string]string) {
+				if fields["cid"] == "0" {
+					started <- struct{}{}
+					<-release
+				}
+			},
+			OverflowHandler: func(event *OverflowEvent) {
+				mu.Lock()
+				events = append(events, *event)
+				mu.Unlock()
+			},
+		}
+		live.Start(context.Background())
+
+		send := func(cid int) {
+			t.Helper()
+			msg := &socketMessage{roomID: 1, body: map[string]string{"type": SpecialUserRespType, "cid": strconv.Itoa(cid)}}
+			if err := live.enqueue(context.Background(), msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		send(0)
+		<-started
+		for cid := 1; cid <= 5; cid++ {
+			send(cid)
+		}
+
+		close(release)
+		// 等待队列清空后再发送，停止丢弃
+		for live.WorkerStats()[0].QueueLen > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		send(6)
+		if err := live.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		dropped := live.WorkerStats()[0].Dropped
+		want := []OverflowEvent{{Dropping: true, Dropped: 1}, {Dropping: false, Dropped: dropped}}
+		mu.Lock()
+		if dropped == 0 || !reflect.DeepEqual(events, want) {
+			t.Errorf("policy %d events = %+v, want %+v", policy, events, want)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestLive_DropOldestOrder(t *testing.T) {
+	const (
+		rooms    = 4
+		messages = 300
+	)
+	var (
+		mu       sync.Mutex
+		received = make(map[int][]int)
+	)
+	live := &Live{
+		MessageBuffer:  4,
+		OverflowPolicy: OverflowDropOldest,
+		RawMessageHandler: func(roomID int, msgType string, fields map[string]string) {
+			cid, _ := strconv.Atoi(fields["cid"])
+			mu.Lock()
+			received[roomID] = append(received[roomID], cid)
+			mu.Unlock()
+			time.Sleep(10 * time.Microsecond)
+		},
+	}
+	live.Start(context.Background())
+
+	// 多个房间共用一个消息分析协程并发放入，控制消息与普通消息交替
+	var wg sync.WaitGroup
+	for roomID := 1; roomID <= rooms; roomID++ {
+		wg.Add(1)
+		go func(roomID int) {
+			defer wg.Done()
+			for i := 0; i < messages; i++ {
+				msgType := BarrageRespType
+				if i%10 == 0 {
+					msgType = ErrorRespType
+				}
+				msg := &socketMessage{roomID: roomID, body: map[string]string{"type": msgType, "cid": strconv.Itoa(i)}}
+				if err := live.enqueue(context.Background(), msg); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(roomID)
+	}
+	wg.Wait()
+	if err := live.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for roomID := 1; roomID <= rooms; roomID++ {
+		cids := received[roomID]
+		controls := 0
+		for i, cid := range cids {
+			if i > 0 && cid <= cids[i-1] {
+				t.Fatalf("房间 %d 消息顺序错误: %d 在 %d 之后", roomID, cid, cids[i-1])
+			}
+			if cid%10 == 0 {
+				controls++
+			}
+		}
+		if controls != messages/10 {
+			t.Errorf("房间 %d 收到 %d 条控制消息，want %d", roomID, controls, messages/10)
+		}
+	}
+}